@@ -0,0 +1,28 @@
+package api
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authorizer 校验请求头 Authorization: Bearer <token>，未配置token时拒绝所有需要授权的操作
+type Authorizer struct {
+	token string
+}
+
+func NewAuthorizer(token string) *Authorizer {
+	return &Authorizer{token: token}
+}
+
+func (a *Authorizer) Authorized(ctx *gin.Context) bool {
+	if len(a.token) == 0 {
+		return false
+	}
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
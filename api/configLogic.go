@@ -0,0 +1,344 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"easy-k8s/pkg/comm"
+)
+
+// ConfigLogic ConfigMap和Secret的查看与编辑
+type ConfigLogic struct {
+	Log           logr.Logger
+	DynamicClient dynamic.Interface
+	Authorizer    *Authorizer
+}
+
+type ConfigListData struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	Type            string `json:"type,omitempty"`
+	Keys            int    `json:"keys"`
+	Age             string `json:"age"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type ConfigMapData struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Data            map[string]string `json:"data"`
+	// BinaryData base64编码
+	BinaryData map[string]string `json:"binaryData,omitempty"`
+}
+
+type SecretData struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	ResourceVersion string            `json:"resourceVersion"`
+	Type            string            `json:"type"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Revealed        bool              `json:"revealed"`
+	Data            []*SecretValue    `json:"data"`
+}
+
+type SecretValue struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+	// Encoding 值为合法的utf8时为text，否则为base64
+	Encoding string `json:"encoding,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+// ConfigUpdateReq 只修改请求中出现的key，未出现的key保持不变
+type ConfigUpdateReq struct {
+	// ResourceVersion 读取时的版本，对象已被修改时返回409
+	ResourceVersion string `json:"resourceVersion"`
+	// Data 新增或修改的值，明文
+	Data map[string]string `json:"data"`
+	// BinaryData 新增或修改的二进制值，base64编码
+	BinaryData map[string]string `json:"binaryData"`
+	Remove     []string          `json:"remove"`
+}
+
+func NewConfigLogic(log logr.Logger, dynamicClient dynamic.Interface, authorizer *Authorizer) *ConfigLogic {
+	return &ConfigLogic{
+		Log:           log.WithName("ConfigLogic"),
+		DynamicClient: dynamicClient,
+		Authorizer:    authorizer,
+	}
+}
+
+func (c *ConfigLogic) ConfigMapList(ctx *gin.Context) {
+	ns := ctx.Param("ns")
+	list, err := c.DynamicClient.Resource(comm.ConfigMapGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.Log.Error(err, "list configmap err")
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := make([]*ConfigListData, 0, len(list.Items))
+	for _, item := range list.Items {
+		var cm v1.ConfigMap
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &cm); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		data = append(data, &ConfigListData{
+			Name:            cm.Name,
+			Namespace:       cm.Namespace,
+			Keys:            len(cm.Data) + len(cm.BinaryData),
+			Age:             translateTimestampSince(cm.CreationTimestamp),
+			ResourceVersion: cm.ResourceVersion,
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (c *ConfigLogic) ConfigMapDetail(ctx *gin.Context) {
+	cm, err := c.getConfigMap(ctx, ctx.Param("ns"), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": configMapData(cm)})
+}
+
+func (c *ConfigLogic) ConfigMapUpdate(ctx *gin.Context) {
+	if !c.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	ns, name := ctx.Param("ns"), ctx.Param("name")
+	var req ConfigUpdateReq
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	if len(req.ResourceVersion) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "resourceVersion is required"})
+		return
+	}
+
+	cm, err := c.getConfigMap(ctx, ns, name)
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	if cm.BinaryData == nil {
+		cm.BinaryData = map[string][]byte{}
+	}
+	for key, value := range req.Data {
+		cm.Data[key] = value
+		delete(cm.BinaryData, key)
+	}
+	for key, value := range req.BinaryData {
+		decoded, err := comm.Base64StdDecode(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "binaryData " + key + " is not base64 encoded"})
+			return
+		}
+		cm.BinaryData[key] = decoded
+		delete(cm.Data, key)
+	}
+	for _, key := range req.Remove {
+		delete(cm.Data, key)
+		delete(cm.BinaryData, key)
+	}
+	cm.ResourceVersion = req.ResourceVersion
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(cm)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	updated, err := c.DynamicClient.Resource(comm.ConfigMapGVR).Namespace(ns).Update(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	if err != nil {
+		c.Log.Error(err, "update configmap err", "namespace", ns, "name", name)
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"resourceVersion": updated.GetResourceVersion()}})
+}
+
+func (c *ConfigLogic) SecretList(ctx *gin.Context) {
+	ns := ctx.Param("ns")
+	list, err := c.DynamicClient.Resource(comm.SecretGVR).Namespace(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.Log.Error(err, "list secret err")
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := make([]*ConfigListData, 0, len(list.Items))
+	for _, item := range list.Items {
+		var secret v1.Secret
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &secret); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		data = append(data, &ConfigListData{
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			Type:            string(secret.Type),
+			Keys:            len(secret.Data),
+			Age:             translateTimestampSince(secret.CreationTimestamp),
+			ResourceVersion: secret.ResourceVersion,
+		})
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// SecretDetail 默认只返回key和值的长度，reveal=true且请求已授权时才返回解码后的值
+func (c *ConfigLogic) SecretDetail(ctx *gin.Context) {
+	reveal := ctx.Query("reveal") == "true"
+	if reveal && !c.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+
+	secret, err := c.getSecret(ctx, ctx.Param("ns"), ctx.Param("name"))
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := &SecretData{
+		Name:            secret.Name,
+		Namespace:       secret.Namespace,
+		ResourceVersion: secret.ResourceVersion,
+		Type:            string(secret.Type),
+		Labels:          secret.Labels,
+		Revealed:        reveal,
+		Data:            make([]*SecretValue, 0, len(secret.Data)),
+	}
+	for key, value := range secret.Data {
+		row := &SecretValue{Key: key, Size: len(value)}
+		if reveal {
+			if utf8.Valid(value) {
+				row.Encoding, row.Value = "text", string(value)
+			} else {
+				row.Encoding, row.Value = "base64", comm.Base64StdEncode(value)
+			}
+		}
+		data.Data = append(data.Data, row)
+	}
+	sort.Slice(data.Data, func(i, j int) bool { return data.Data[i].Key < data.Data[j].Key })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (c *ConfigLogic) SecretUpdate(ctx *gin.Context) {
+	if !c.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	ns, name := ctx.Param("ns"), ctx.Param("name")
+	var req ConfigUpdateReq
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	if len(req.ResourceVersion) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "resourceVersion is required"})
+		return
+	}
+
+	secret, err := c.getSecret(ctx, ns, name)
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range req.Data {
+		secret.Data[key] = []byte(value)
+	}
+	for key, value := range req.BinaryData {
+		decoded, err := comm.Base64StdDecode(value)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "binaryData " + key + " is not base64 encoded"})
+			return
+		}
+		secret.Data[key] = decoded
+	}
+	for _, key := range req.Remove {
+		delete(secret.Data, key)
+	}
+	secret.ResourceVersion = req.ResourceVersion
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(secret)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	updated, err := c.DynamicClient.Resource(comm.SecretGVR).Namespace(ns).Update(ctx, &unstructured.Unstructured{Object: obj}, metav1.UpdateOptions{})
+	if err != nil {
+		c.Log.Error(err, "update secret err", "namespace", ns, "name", name)
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"resourceVersion": updated.GetResourceVersion()}})
+}
+
+func (c *ConfigLogic) getConfigMap(ctx *gin.Context, ns, name string) (*v1.ConfigMap, error) {
+	obj, err := c.DynamicClient.Resource(comm.ConfigMapGVR).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			c.Log.Error(err, "get configmap err", "namespace", ns, "name", name)
+		}
+		return nil, err
+	}
+	var cm v1.ConfigMap
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &cm); err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
+
+func (c *ConfigLogic) getSecret(ctx *gin.Context, ns, name string) (*v1.Secret, error) {
+	obj, err := c.DynamicClient.Resource(comm.SecretGVR).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			c.Log.Error(err, "get secret err", "namespace", ns, "name", name)
+		}
+		return nil, err
+	}
+	var secret v1.Secret
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+func configMapData(cm *v1.ConfigMap) *ConfigMapData {
+	data := &ConfigMapData{
+		Name:            cm.Name,
+		Namespace:       cm.Namespace,
+		ResourceVersion: cm.ResourceVersion,
+		Labels:          cm.Labels,
+		Data:            cm.Data,
+	}
+	if len(cm.BinaryData) != 0 {
+		data.BinaryData = make(map[string]string, len(cm.BinaryData))
+		for key, value := range cm.BinaryData {
+			data.BinaryData[key] = comm.Base64StdEncode(value)
+		}
+	}
+	return data
+}
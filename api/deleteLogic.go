@@ -0,0 +1,391 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/metadata"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/apply"
+)
+
+// DeleteLogic 任意对象的删除，以及删除卡住时的finalizer和依赖对象分析
+type DeleteLogic struct {
+	Log            logr.Logger
+	Applier        *apply.Applier
+	Discovery      discovery.CachedDiscoveryInterface
+	MetadataClient metadata.Interface
+	Authorizer     *Authorizer
+}
+
+type DeleteData struct {
+	Kind               string `json:"kind"`
+	Namespace          string `json:"namespace,omitempty"`
+	Name               string `json:"name"`
+	DryRun             bool   `json:"dryRun"`
+	Propagation        string `json:"propagation"`
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+	// Deleted 为false表示对象仍在Terminating，Terminating中为卡住的原因
+	Deleted     bool             `json:"deleted"`
+	Terminating *TerminatingData `json:"terminating,omitempty"`
+}
+
+type TerminatingData struct {
+	Kind              string           `json:"kind"`
+	Namespace         string           `json:"namespace,omitempty"`
+	Name              string           `json:"name"`
+	UID               string           `json:"uid"`
+	Terminating       bool             `json:"terminating"`
+	DeletionTimestamp string           `json:"deletionTimestamp,omitempty"`
+	Duration          string           `json:"duration,omitempty"`
+	Finalizers        []string         `json:"finalizers"`
+	Conditions        []*ConditionData `json:"conditions,omitempty"`
+	// Dependents dependents=true时才查找，需要遍历所有可list的资源
+	Dependents []*DependentData `json:"dependents"`
+	// FailedResources 查找依赖对象时无法list的资源
+	FailedResources []string `json:"failedResources,omitempty"`
+}
+
+type ConditionData struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// DependentData ownerReferences指向该对象的子对象，foreground删除时BlockOwnerDeletion的子对象会阻塞删除
+type DependentData struct {
+	UID                types.UID `json:"uid"`
+	Group              string    `json:"group"`
+	Kind               string    `json:"kind"`
+	Namespace          string    `json:"namespace,omitempty"`
+	Name               string    `json:"name"`
+	BlockOwnerDeletion bool      `json:"blockOwnerDeletion"`
+	Terminating        bool      `json:"terminating"`
+	Finalizers         []string  `json:"finalizers,omitempty"`
+}
+
+var deletePropagations = map[string]metav1.DeletionPropagation{
+	"foreground": metav1.DeletePropagationForeground,
+	"background": metav1.DeletePropagationBackground,
+	"orphan":     metav1.DeletePropagationOrphan,
+}
+
+func NewDeleteLogic(log logr.Logger, applier *apply.Applier, discoveryClient discovery.CachedDiscoveryInterface, metadataClient metadata.Interface, authorizer *Authorizer) *DeleteLogic {
+	return &DeleteLogic{
+		Log:            log.WithName("DeleteLogic"),
+		Applier:        applier,
+		Discovery:      discoveryClient,
+		MetadataClient: metadataClient,
+		Authorizer:     authorizer,
+	}
+}
+
+// DeleteByNs DELETE /resources/:group/:version/:resource/:ns 集群级别的资源 :ns 即为对象名称
+func (d *DeleteLogic) DeleteByNs(ctx *gin.Context) {
+	d.delete(ctx, "", ctx.Param("ns"))
+}
+
+// DeleteDetail DELETE /resources/:group/:version/:resource/:ns/:name
+func (d *DeleteLogic) DeleteDetail(ctx *gin.Context) {
+	d.delete(ctx, ctx.Param("ns"), ctx.Param("name"))
+}
+
+// TerminatingByNs GET /terminating/:group/:version/:resource/:ns
+func (d *DeleteLogic) TerminatingByNs(ctx *gin.Context) {
+	d.terminating(ctx, "", ctx.Param("ns"))
+}
+
+// TerminatingDetail GET /terminating/:group/:version/:resource/:ns/:name
+func (d *DeleteLogic) TerminatingDetail(ctx *gin.Context) {
+	d.terminating(ctx, ctx.Param("ns"), ctx.Param("name"))
+}
+
+// RemoveFinalizersByNs DELETE /finalizers/:group/:version/:resource/:ns
+func (d *DeleteLogic) RemoveFinalizersByNs(ctx *gin.Context) {
+	d.removeFinalizers(ctx, "", ctx.Param("ns"))
+}
+
+// RemoveFinalizersDetail DELETE /finalizers/:group/:version/:resource/:ns/:name
+func (d *DeleteLogic) RemoveFinalizersDetail(ctx *gin.Context) {
+	d.removeFinalizers(ctx, ctx.Param("ns"), ctx.Param("name"))
+}
+
+// delete propagation=foreground|background|orphan，gracePeriod覆盖对象默认的优雅退出时间，dryRun=true时只做服务端校验，
+// 对象未立即删除时返回卡住的原因，dependents=true时同时查找子对象
+func (d *DeleteLogic) delete(ctx *gin.Context, ns, name string) {
+	if !d.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	mapping, ok := d.mapping(ctx)
+	if !ok {
+		return
+	}
+
+	propagationName := ctx.DefaultQuery("propagation", "background")
+	propagation, ok := deletePropagations[propagationName]
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "propagation must be foreground, background or orphan"})
+		return
+	}
+	data := &DeleteData{Kind: mapping.GroupVersionKind.Kind, Namespace: ns, Name: name, DryRun: ctx.Query("dryRun") == "true", Propagation: propagationName}
+	options := metav1.DeleteOptions{PropagationPolicy: &propagation}
+	if gracePeriod := ctx.Query("gracePeriod"); len(gracePeriod) != 0 {
+		seconds, err := strconv.ParseInt(gracePeriod, 10, 64)
+		if err != nil || seconds < 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "gracePeriod must be a non-negative integer"})
+			return
+		}
+		options.GracePeriodSeconds, data.GracePeriodSeconds = &seconds, &seconds
+	}
+	if data.DryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	resourceClient := d.Applier.ResourceInterface(mapping, ns)
+	if err := resourceClient.Delete(ctx, name, options); err != nil {
+		if !apierrors.IsNotFound(err) {
+			d.Log.Error(err, "delete resource err", "gvr", mapping.Resource.String(), "namespace", ns, "name", name)
+		}
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	if data.DryRun {
+		ctx.JSON(http.StatusOK, gin.H{"data": data})
+		return
+	}
+
+	// 有finalizer或foreground删除时对象不会立即消失
+	obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		data.Deleted = true
+		ctx.JSON(http.StatusOK, gin.H{"data": data})
+		return
+	}
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	data.Terminating = d.terminatingData(ctx, mapping, obj, ctx.Query("dependents") == "true")
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// terminating 返回对象的finalizer、status.conditions，用于分析删除卡住的原因；
+// dependents=true时遍历资源查找仍然存在的子对象，集群级别的对象会遍历所有namespace
+func (d *DeleteLogic) terminating(ctx *gin.Context, ns, name string) {
+	mapping, ok := d.mapping(ctx)
+	if !ok {
+		return
+	}
+	obj, err := d.Applier.ResourceInterface(mapping, ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": d.terminatingData(ctx, mapping, obj, ctx.Query("dependents") == "true")})
+}
+
+// removeFinalizers 移除对象的finalizer，finalizer参数可重复指定，不指定时移除全部。
+// 通过resourceVersion保证移除期间对象未被修改
+func (d *DeleteLogic) removeFinalizers(ctx *gin.Context, ns, name string) {
+	if !d.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	mapping, ok := d.mapping(ctx)
+	if !ok {
+		return
+	}
+	resourceClient := d.Applier.ResourceInterface(mapping, ns)
+	obj, err := resourceClient.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	remove := make(map[string]struct{})
+	for _, finalizer := range ctx.QueryArray("finalizer") {
+		remove[finalizer] = struct{}{}
+	}
+	remaining, removed := []string{}, []string{}
+	for _, finalizer := range obj.GetFinalizers() {
+		if _, ok := remove[finalizer]; len(remove) == 0 || ok {
+			removed = append(removed, finalizer)
+		} else {
+			remaining = append(remaining, finalizer)
+		}
+	}
+	if len(removed) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"removed": removed, "remaining": remaining}})
+		return
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"finalizers":      remaining,
+			"resourceVersion": obj.GetResourceVersion(),
+		},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	if _, err = resourceClient.Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		d.Log.Error(err, "remove finalizers err", "gvr", mapping.Resource.String(), "namespace", ns, "name", name)
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	d.Log.Info("removed finalizers", "gvr", mapping.Resource.String(), "namespace", ns, "name", name, "finalizers", removed)
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"removed": removed, "remaining": remaining}})
+}
+
+func (d *DeleteLogic) mapping(ctx *gin.Context) (*meta.RESTMapping, bool) {
+	gvr := schema.GroupVersionResource{Group: ctx.Param("group"), Version: ctx.Param("version"), Resource: ctx.Param("resource")}
+	if gvr.Group == coreGroup {
+		gvr.Group = ""
+	}
+	mapping, err := d.Applier.ResourceMapping(gvr)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": err.Error()})
+		return nil, false
+	}
+	return mapping, true
+}
+
+func (d *DeleteLogic) terminatingData(ctx context.Context, mapping *meta.RESTMapping, obj *unstructured.Unstructured, dependents bool) *TerminatingData {
+	data := &TerminatingData{
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        string(obj.GetUID()),
+		Finalizers: obj.GetFinalizers(),
+		Conditions: objectConditions(obj),
+	}
+	if data.Finalizers == nil {
+		data.Finalizers = []string{}
+	}
+	if deletionTimestamp := obj.GetDeletionTimestamp(); deletionTimestamp != nil {
+		data.Terminating = true
+		data.DeletionTimestamp = deletionTimestamp.String()
+		data.Duration = translateTimestampSince(*deletionTimestamp)
+	}
+	if dependents {
+		data.Dependents, data.FailedResources = d.dependents(ctx, mapping, obj)
+	}
+	return data
+}
+
+// dependents 返回ownerReferences指向该对象的子对象
+func (d *DeleteLogic) dependents(ctx context.Context, mapping *meta.RESTMapping, obj *unstructured.Unstructured) ([]*DependentData, []string) {
+	owned, failed := scanOwned(ctx, d.Discovery, d.MetadataClient, obj.GetNamespace(), mapping.Scope.Name() == meta.RESTScopeNameNamespace)
+	dependents := owned[obj.GetUID()]
+	if dependents == nil {
+		dependents = []*DependentData{}
+	}
+	sort.Slice(dependents, func(i, j int) bool {
+		if dependents[i].Kind != dependents[j].Kind {
+			return dependents[i].Kind < dependents[j].Kind
+		}
+		return dependents[i].Name < dependents[j].Name
+	})
+	return dependents, failed
+}
+
+// scanOwnedPageSize 遍历资源时每次list的数量，避免一次返回大量对象
+const scanOwnedPageSize = 500
+
+// scanOwned 通过metadata client分页遍历所有可list的资源，按owner的uid汇总子对象。
+// namespaced为true时只遍历namespace下的资源，否则遍历所有namespace和集群级别的资源
+func scanOwned(ctx context.Context, discoveryClient discovery.DiscoveryInterface, metadataClient metadata.Interface, namespace string, namespaced bool) (map[types.UID][]*DependentData, []string) {
+	owned := make(map[types.UID][]*DependentData)
+	var failed []string
+
+	lists, err := discoveryClient.ServerPreferredResources()
+	if err != nil && len(lists) == 0 {
+		return owned, []string{err.Error()}
+	}
+	if !namespaced {
+		namespace = metav1.NamespaceAll
+	}
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !hasVerb(resource.Verbs, "list") || (namespaced && !resource.Namespaced) {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			options := metav1.ListOptions{Limit: scanOwnedPageSize}
+			for {
+				items, err := metadataClient.Resource(gvr).Namespace(namespace).List(ctx, options)
+				if err != nil {
+					failed = append(failed, gvr.String())
+					break
+				}
+				for _, item := range items.Items {
+					for _, ref := range item.OwnerReferences {
+						owned[ref.UID] = append(owned[ref.UID], &DependentData{
+							UID:                item.UID,
+							Group:              gv.Group,
+							Kind:               resource.Kind,
+							Namespace:          item.Namespace,
+							Name:               item.Name,
+							BlockOwnerDeletion: ref.BlockOwnerDeletion != nil && *ref.BlockOwnerDeletion,
+							Terminating:        item.DeletionTimestamp != nil,
+							Finalizers:         item.Finalizers,
+						})
+					}
+				}
+				if options.Continue = items.Continue; len(options.Continue) == 0 {
+					break
+				}
+			}
+		}
+	}
+	return owned, failed
+}
+
+// objectConditions 读取status.conditions，例如namespace的NamespaceContentRemaining、NamespaceFinalizersRemaining
+func objectConditions(obj *unstructured.Unstructured) []*ConditionData {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	var data []*ConditionData
+	for _, item := range conditions {
+		condition, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		row := &ConditionData{}
+		row.Type, _, _ = unstructured.NestedString(condition, "type")
+		row.Status, _, _ = unstructured.NestedString(condition, "status")
+		row.Reason, _, _ = unstructured.NestedString(condition, "reason")
+		row.Message, _, _ = unstructured.NestedString(condition, "message")
+		data = append(data, row)
+	}
+	return data
+}
+
+func hasVerb(verbs []string, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/metadata"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// testDiscovery FakeDiscovery不返回ServerPreferredResources
+type testDiscovery struct {
+	*fakediscovery.FakeDiscovery
+}
+
+func (d *testDiscovery) ServerPreferredResources() ([]*metav1.APIResourceList, error) {
+	return d.Resources, nil
+}
+
+// pagedMetadataClient 按continue返回分页结果，fake metadata client不会传递Limit和Continue
+type pagedMetadataClient struct {
+	metadata.ResourceInterface
+	pages    map[string]*metav1.PartialObjectMetadataList
+	requests []metav1.ListOptions
+}
+
+func (c *pagedMetadataClient) Resource(schema.GroupVersionResource) metadata.Getter { return c }
+
+func (c *pagedMetadataClient) Namespace(string) metadata.ResourceInterface { return c }
+
+func (c *pagedMetadataClient) List(_ context.Context, options metav1.ListOptions) (*metav1.PartialObjectMetadataList, error) {
+	c.requests = append(c.requests, options)
+	return c.pages[options.Continue], nil
+}
+
+func ownedItem(name string, owner types.UID) metav1.PartialObjectMetadata {
+	return metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{
+		Namespace:       "default",
+		Name:            name,
+		UID:             types.UID(name),
+		OwnerReferences: []metav1.OwnerReference{{UID: owner}},
+	}}
+}
+
+func TestScanOwnedPaginates(t *testing.T) {
+	discoveryClient := &testDiscovery{&fakediscovery.FakeDiscovery{Fake: &k8stesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: []string{"list"}},
+			{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: []string{"get"}},
+		},
+	}}}}}
+
+	metadataClient := &pagedMetadataClient{pages: map[string]*metav1.PartialObjectMetadataList{
+		"":       {ListMeta: metav1.ListMeta{Continue: "page-2"}, Items: []metav1.PartialObjectMetadata{ownedItem("web-1", "rs")}},
+		"page-2": {Items: []metav1.PartialObjectMetadata{ownedItem("web-2", "rs"), ownedItem("job-1", "job")}},
+	}}
+
+	owned, failed := scanOwned(context.Background(), discoveryClient, metadataClient, "default", true)
+	if len(failed) != 0 {
+		t.Fatalf("failed resources = %v", failed)
+	}
+	requests := metadataClient.requests
+	if len(requests) != 2 || requests[0].Limit != scanOwnedPageSize || requests[1].Continue != "page-2" {
+		t.Errorf("list requests = %+v, want 2 pages of %d", requests, scanOwnedPageSize)
+	}
+	if len(owned["rs"]) != 2 || len(owned["job"]) != 1 {
+		t.Errorf("owned = %v, want 2 dependents of rs and 1 of job", owned)
+	}
+	if dependent := owned["job"][0]; dependent.Kind != "Pod" || dependent.Name != "job-1" {
+		t.Errorf("job dependent = %+v", dependent)
+	}
+}
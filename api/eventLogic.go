@@ -0,0 +1,156 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/events"
+	"easy-k8s/pkg/k8s/informerfactory"
+)
+
+type EventLogic struct {
+	Log           logr.Logger
+	EventInformer cache.SharedIndexInformer
+	Archive       *events.Archive
+}
+
+type EventListReq struct {
+	Namespace string `json:"namespace" form:"namespace"`
+	Type      string `json:"type" form:"type"`
+	Reason    string `json:"reason" form:"reason"`
+	// Kind Name 为事件关联对象的类型和名称
+	Kind string `json:"kind" form:"kind"`
+	Name string `json:"name" form:"name"`
+	// Since Until 为unix时间戳(秒)，Window 为最近一段时间，如 30m、24h，与Since同时指定时以Window为准
+	Since  int64  `json:"since" form:"since"`
+	Until  int64  `json:"until" form:"until"`
+	Window string `json:"window" form:"window"`
+	Limit  int    `json:"limit" form:"limit"`
+}
+
+const (
+	defaultEventLimit = 500
+	sseKeepAlive      = 30 * time.Second
+)
+
+func NewEventLogic(log logr.Logger, eventInformer cache.SharedIndexInformer, archive *events.Archive) *EventLogic {
+	return &EventLogic{
+		Log:           log.WithName("EventLogic"),
+		EventInformer: eventInformer,
+		Archive:       archive,
+	}
+}
+
+// EventList GET /events 合并informer缓存中的当前事件和本地存储中超过TTL的历史事件
+func (e *EventLogic) EventList(ctx *gin.Context) {
+	filter, limit, ok := e.filter(ctx)
+	if !ok {
+		return
+	}
+
+	latest := make(map[string]*events.Event)
+	archived, err := e.Archive.Query(filter, 0)
+	if err != nil {
+		e.Log.Error(err, "query archived events err")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	for _, ev := range archived {
+		latest[ev.UID] = ev
+	}
+
+	objs, err := e.cachedEvents(filter)
+	if err != nil {
+		e.Log.Error(err, "list cached events err")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	for _, obj := range objs {
+		ev := events.FromK8s(obj.(*eventsv1.Event))
+		if !filter.Match(ev) {
+			continue
+		}
+		if old, ok := latest[ev.UID]; !ok || ev.LastTime.After(old.LastTime) {
+			latest[ev.UID] = ev
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": events.SortAndLimit(latest, limit)})
+}
+
+// EventStream GET /events/stream 以SSE推送新事件，支持与 /events 相同的过滤条件(时间条件除外)
+func (e *EventLogic) EventStream(ctx *gin.Context) {
+	filter, _, ok := e.filter(ctx)
+	if !ok {
+		return
+	}
+	filter.Since, filter.Until = time.Time{}, time.Time{}
+
+	ch, cancel := e.Archive.Subscribe()
+	defer cancel()
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case ev := <-ch:
+			if filter.Match(ev) {
+				ctx.SSEvent("event", ev)
+			}
+			return true
+		case <-keepAlive.C:
+			ctx.SSEvent("ping", time.Now().Unix())
+			return true
+		case <-ctx.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func (e *EventLogic) filter(ctx *gin.Context) (*events.Filter, int, bool) {
+	var req EventListReq
+	if err := ctx.BindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return nil, 0, false
+	}
+	filter := &events.Filter{Namespace: req.Namespace, Type: req.Type, Reason: req.Reason, Kind: req.Kind, Name: req.Name}
+	if req.Since > 0 {
+		filter.Since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		filter.Until = time.Unix(req.Until, 0)
+	}
+	if len(req.Window) != 0 {
+		window, err := time.ParseDuration(req.Window)
+		if err != nil || window <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "invalid window"})
+			return nil, 0, false
+		}
+		filter.Since = time.Now().Add(-window)
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultEventLimit
+	}
+	return filter, limit, true
+}
+
+// cachedEvents 优先使用最精确的索引缩小范围
+func (e *EventLogic) cachedEvents(filter *events.Filter) ([]any, error) {
+	indexer := e.EventInformer.GetIndexer()
+	switch {
+	case len(filter.Kind) != 0 && len(filter.Name) != 0 && len(filter.Namespace) != 0:
+		return indexer.ByIndex(informerfactory.RegardingIndex, events.RegardingKey(filter.Kind, filter.Namespace, filter.Name))
+	case len(filter.Reason) != 0:
+		return indexer.ByIndex(informerfactory.ReasonIndex, filter.Reason)
+	case len(filter.Namespace) != 0:
+		return indexer.ByIndex(cache.NamespaceIndex, filter.Namespace)
+	default:
+		return indexer.List(), nil
+	}
+}
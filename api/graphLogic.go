@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/apply"
+	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/reference"
+)
+
+// GraphLogic 基于ownerReferences的对象关系图，pod额外关联Service、ConfigMap和PVC
+type GraphLogic struct {
+	Log             logr.Logger
+	Applier         *apply.Applier
+	Discovery       discovery.CachedDiscoveryInterface
+	MetadataClient  metadata.Interface
+	ChildInformers  map[schema.GroupVersionResource]cache.SharedIndexInformer
+	PodInformer     cache.SharedIndexInformer
+	ServiceInformer cache.SharedIndexInformer
+	Dependency      *PodDependencyInformers
+}
+
+type GraphNode struct {
+	ID        string `json:"id"`
+	Group     string `json:"group"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+	// Target 为请求的对象，Missing 为被引用但不存在的对象
+	Target  bool `json:"target,omitempty"`
+	Missing bool `json:"missing,omitempty"`
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+type GraphData struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+	// Syncing 尚未同步完成的informer，其子对象暂未包含在图中
+	Syncing         []string `json:"syncing,omitempty"`
+	FailedResources []string `json:"failedResources,omitempty"`
+}
+
+// 关系类型
+const (
+	EdgeOwns       = "owns"
+	EdgeSelects    = "selects"
+	EdgeReferences = "references"
+)
+
+// ownerReferences链的最大深度，防止异常数据导致的循环
+const maxGraphDepth = 10
+
+// 不开启scan时通过metadata informer查找子对象的资源，informer在RunInformerFactory中注册，pod使用已有的pod informer
+var graphChildKinds = map[schema.GroupVersionResource]string{
+	comm.ReplicaSetGVR: "ReplicaSet",
+	comm.JobGVR:        "Job",
+}
+
+type graphBuilder struct {
+	*GraphLogic
+	ctx   context.Context
+	data  *GraphData
+	nodes map[string]*GraphNode
+	edges map[GraphEdge]struct{}
+	// scan=true时预先遍历所有资源得到的子对象
+	owned map[types.UID][]*DependentData
+}
+
+func NewGraphLogic(log logr.Logger, applier *apply.Applier, discoveryClient discovery.CachedDiscoveryInterface, metadataClient metadata.Interface, childInformers map[schema.GroupVersionResource]cache.SharedIndexInformer, podInformer, serviceInformer cache.SharedIndexInformer, dependency *PodDependencyInformers) *GraphLogic {
+	return &GraphLogic{
+		Log:             log.WithName("GraphLogic"),
+		Applier:         applier,
+		Discovery:       discoveryClient,
+		MetadataClient:  metadataClient,
+		ChildInformers:  childInformers,
+		PodInformer:     podInformer,
+		ServiceInformer: serviceInformer,
+		Dependency:      dependency,
+	}
+}
+
+// Graph GET /graph/:kind/:ns/:name kind支持kubectl风格的资源名，集群级别的资源忽略 :ns。
+// 先沿ownerReferences向上找到顶层owner，再从顶层向下展开所有子对象；
+// 默认通过informer查找ReplicaSet、Job和Pod，scan=true时遍历namespace下所有资源以支持自定义控制器
+func (g *GraphLogic) Graph(ctx *gin.Context) {
+	mapping, err := g.Applier.NameMapping(ctx.Param("kind"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": err.Error()})
+		return
+	}
+	ns := ctx.Param("ns")
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if !namespaced {
+		ns = ""
+	}
+	obj, err := g.Applier.ResourceInterface(mapping, ns).Get(ctx, ctx.Param("name"), metav1.GetOptions{})
+	if err != nil {
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	b := &graphBuilder{
+		GraphLogic: g,
+		ctx:        ctx,
+		data:       &GraphData{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}},
+		nodes:      make(map[string]*GraphNode),
+		edges:      make(map[GraphEdge]struct{}),
+	}
+	if ctx.Query("scan") == "true" {
+		b.owned, b.data.FailedResources = scanOwned(ctx, g.Discovery, g.MetadataClient, ns, namespaced)
+	}
+
+	target := b.addNode(mapping.GroupVersionKind.Group, obj.GetKind(), obj.GetNamespace(), obj.GetName(), obj.GetUID())
+	target.Target = true
+	roots := b.walkUp(obj.GetOwnerReferences(), target, obj.GetNamespace(), 0)
+	if len(roots) == 0 {
+		roots = []*GraphNode{target}
+	}
+	for _, root := range roots {
+		b.walkDown(root, 0)
+	}
+	b.addPodRelations()
+
+	sort.Slice(b.data.Nodes, func(i, j int) bool {
+		if b.data.Nodes[i].Kind != b.data.Nodes[j].Kind {
+			return b.data.Nodes[i].Kind < b.data.Nodes[j].Kind
+		}
+		return b.data.Nodes[i].Name < b.data.Nodes[j].Name
+	})
+	ctx.JSON(http.StatusOK, gin.H{"data": b.data})
+}
+
+// walkUp 递归获取owner，返回没有owner的顶层对象
+func (b *graphBuilder) walkUp(refs []metav1.OwnerReference, child *GraphNode, namespace string, depth int) []*GraphNode {
+	if depth >= maxGraphDepth {
+		return nil
+	}
+	var roots []*GraphNode
+	for _, ref := range refs {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+		if _, exists := b.nodes[string(ref.UID)]; exists {
+			b.addEdge(string(ref.UID), child.ID, EdgeOwns)
+			continue
+		}
+
+		mapping, err := b.Applier.Mapping(gv.WithKind(ref.Kind))
+		if err != nil {
+			b.Log.Error(err, "owner kind mapping err", "kind", ref.Kind)
+			continue
+		}
+		ownerNs := namespace
+		if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+			ownerNs = ""
+		}
+		owner, err := b.Applier.ResourceInterface(mapping, ownerNs).Get(b.ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				b.Log.Error(err, "get owner err", "kind", ref.Kind, "namespace", ownerNs, "name", ref.Name)
+			}
+			node := b.addNode(gv.Group, ref.Kind, ownerNs, ref.Name, ref.UID)
+			node.Missing = true
+			b.addEdge(node.ID, child.ID, EdgeOwns)
+			continue
+		}
+
+		node := b.addNode(gv.Group, ref.Kind, ownerNs, ref.Name, owner.GetUID())
+		b.addEdge(node.ID, child.ID, EdgeOwns)
+		if len(owner.GetOwnerReferences()) == 0 {
+			roots = append(roots, node)
+			continue
+		}
+		roots = append(roots, b.walkUp(owner.GetOwnerReferences(), node, ownerNs, depth+1)...)
+	}
+	return roots
+}
+
+// walkDown 递归展开子对象
+func (b *graphBuilder) walkDown(parent *GraphNode, depth int) {
+	if depth >= maxGraphDepth || parent.Missing {
+		return
+	}
+	for _, child := range b.children(parent) {
+		_, visited := b.nodes[string(child.UID)]
+		node := b.addNode(child.Group, child.Kind, child.Namespace, child.Name, child.UID)
+		b.addEdge(parent.ID, node.ID, EdgeOwns)
+		if !visited {
+			b.walkDown(node, depth+1)
+		}
+	}
+}
+
+func (b *graphBuilder) children(parent *GraphNode) []*DependentData {
+	uid := types.UID(parent.ID)
+	if b.owned != nil {
+		return b.owned[uid]
+	}
+
+	var children []*DependentData
+	pods, err := b.PodInformer.GetIndexer().ByIndex(informerfactory.OwnerUIDIndex, parent.ID)
+	if err != nil {
+		b.Log.Error(err, "get pods by owner err", "uid", parent.ID)
+	}
+	for _, obj := range pods {
+		pod := obj.(*v1.Pod)
+		children = append(children, &DependentData{UID: pod.UID, Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
+	}
+
+	for gvr, kind := range graphChildKinds {
+		informer := b.ChildInformers[gvr]
+		if !informer.HasSynced() {
+			b.data.Syncing = appendUnique(b.data.Syncing, gvr.String())
+			continue
+		}
+		objs, err := informer.GetIndexer().ByIndex(informerfactory.OwnerUIDIndex, parent.ID)
+		if err != nil {
+			b.Log.Error(err, "get children by owner err", "gvr", gvr.String(), "uid", parent.ID)
+			continue
+		}
+		for _, obj := range objs {
+			item := obj.(*metav1.PartialObjectMetadata)
+			children = append(children, &DependentData{UID: item.UID, Group: gvr.Group, Kind: kind, Namespace: item.Namespace, Name: item.Name})
+		}
+	}
+	return children
+}
+
+// addPodRelations 为图中的pod添加选中它的Service以及它引用的ConfigMap和PVC
+func (b *graphBuilder) addPodRelations() {
+	for _, node := range b.data.Nodes {
+		if node.Group != "" || node.Kind != "Pod" || node.Missing {
+			continue
+		}
+		obj, exists, err := b.PodInformer.GetStore().GetByKey(node.Namespace + "/" + node.Name)
+		if err != nil || !exists {
+			continue
+		}
+		pod := obj.(*v1.Pod)
+		node.Status = string(pod.Status.Phase)
+
+		services, err := b.ServiceInformer.GetIndexer().ByIndex(cache.NamespaceIndex, pod.Namespace)
+		if err != nil {
+			b.Log.Error(err, "list services err", "namespace", pod.Namespace)
+		}
+		for _, obj := range services {
+			svc := obj.(*v1.Service)
+			if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			svcNode := b.addNode("", "Service", svc.Namespace, svc.Name, svc.UID)
+			b.addEdge(svcNode.ID, node.ID, EdgeSelects)
+		}
+
+		b.addReferences(node, pod, reference.KindConfigMap, b.Dependency.ConfigMap)
+		b.addReferences(node, pod, reference.KindPersistentVolumeClaim, b.Dependency.PersistentVolumeClaim)
+	}
+}
+
+func (b *graphBuilder) addReferences(podNode *GraphNode, pod *v1.Pod, kind string, informer cache.SharedIndexInformer) {
+	for _, name := range reference.PodReferenceNames(pod, kind) {
+		var uid types.UID
+		obj, exists, err := informer.GetStore().GetByKey(pod.Namespace + "/" + name)
+		if err == nil && exists {
+			if accessor, err := meta.Accessor(obj); err == nil {
+				uid = accessor.GetUID()
+			}
+		}
+		node := b.addNode("", kind, pod.Namespace, name, uid)
+		node.Missing = !exists
+		if claim, ok := obj.(*v1.PersistentVolumeClaim); ok {
+			node.Status = string(claim.Status.Phase)
+		}
+		b.addEdge(podNode.ID, node.ID, EdgeReferences)
+	}
+}
+
+// addNode 以uid作为节点id，不存在的对象使用 kind/namespace/name
+func (b *graphBuilder) addNode(group, kind, namespace, name string, uid types.UID) *GraphNode {
+	id := string(uid)
+	if len(id) == 0 {
+		id = kind + "/" + namespace + "/" + name
+	}
+	if node, exists := b.nodes[id]; exists {
+		return node
+	}
+	node := &GraphNode{ID: id, Group: group, Kind: kind, Namespace: namespace, Name: name}
+	b.nodes[id] = node
+	b.data.Nodes = append(b.data.Nodes, node)
+	return node
+}
+
+func (b *graphBuilder) addEdge(from, to, edgeType string) {
+	edge := GraphEdge{From: from, To: to, Type: edgeType}
+	if _, exists := b.edges[edge]; exists {
+		return
+	}
+	b.edges[edge] = struct{}{}
+	b.data.Edges = append(b.data.Edges, &edge)
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"errors"
+	"net/http"
 	"time"
 
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/duration"
 )
@@ -17,6 +21,40 @@ type PodData struct {
 	UseGpu      bool   `json:"useGpu"`
 	UseGpuCount string `json:"useGpuCount"`
 	GpuProduct  string `json:"gpuProduct"`
+	CpuUsage    string `json:"cpuUsage,omitempty"`
+	MemoryUsage string `json:"memoryUsage,omitempty"`
+}
+
+// apiErrorCode 将apiserver返回的错误转换为http状态码
+func apiErrorCode(err error) int {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	return http.StatusInternalServerError
+}
+
+// addResourceList 将new中的资源累加到list
+func addResourceList(list, new v1.ResourceList) {
+	for name, quantity := range new {
+		if value, ok := list[name]; !ok {
+			list[name] = quantity.DeepCopy()
+		} else {
+			value.Add(quantity)
+			list[name] = value
+		}
+	}
+}
+
+func resourceListData(list v1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	data := make(map[string]string, len(list))
+	for name, quantity := range list {
+		data[string(name)] = quantity.String()
+	}
+	return data
 }
 
 func translateTimestampSince(timestamp metav1.Time) string {
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/apply"
+)
+
+// ManifestLogic 多文档YAML的diff预览和批量apply
+type ManifestLogic struct {
+	Log        logr.Logger
+	Applier    *apply.Applier
+	Authorizer *Authorizer
+}
+
+// manifest处理模式
+const (
+	manifestModeDiff  = "diff"
+	manifestModeApply = "apply"
+)
+
+// prune删除的对象
+const operationPruned = "pruned"
+
+type ManifestObjectResult struct {
+	Index     int               `json:"index"`
+	Kind      string            `json:"kind"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name"`
+	Operation string            `json:"operation,omitempty"`
+	Diff      string            `json:"diff,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	Conflicts []*apply.Conflict `json:"conflicts,omitempty"`
+}
+
+type ManifestData struct {
+	Mode    string                  `json:"mode"`
+	DryRun  bool                    `json:"dryRun"`
+	Failed  int                     `json:"failed"`
+	Objects []*ManifestObjectResult `json:"objects"`
+}
+
+// manifest中出现的资源类型及其所在namespace，用于prune
+type pruneScope struct {
+	mapping    *meta.RESTMapping
+	namespaces map[string]struct{}
+}
+
+func NewManifestLogic(log logr.Logger, applier *apply.Applier, authorizer *Authorizer) *ManifestLogic {
+	return &ManifestLogic{
+		Log:        log.WithName("ManifestLogic"),
+		Applier:    applier,
+		Authorizer: authorizer,
+	}
+}
+
+// ManifestApply POST /manifests 请求体为多文档YAML，按Namespace、CRD、其他对象的顺序处理。
+// mode=diff(默认)时通过dry-run计算每个对象与集群状态的差异，mode=apply时真正应用；
+// prune=<labelSelector>时删除带有该label、但不在manifest中的同类对象，有对象失败时不做prune
+func (m *ManifestLogic) ManifestApply(ctx *gin.Context) {
+	if !m.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	mode := ctx.DefaultQuery("mode", manifestModeDiff)
+	if mode != manifestModeDiff && mode != manifestModeApply {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "mode must be diff or apply"})
+		return
+	}
+	var pruneSelector labels.Selector
+	if prune := ctx.Query("prune"); len(prune) != 0 {
+		selector, err := labels.Parse(prune)
+		if err != nil || selector.Empty() {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "prune requires a non-empty label selector"})
+			return
+		}
+		pruneSelector = selector
+	}
+
+	body, ok := readYamlBody(ctx)
+	if !ok {
+		return
+	}
+	objs, err := apply.ParseManifest(body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	apply.SortByDependency(objs)
+
+	data := &ManifestData{Mode: mode, DryRun: mode == manifestModeDiff || ctx.Query("dryRun") == "true"}
+	opts := apply.Options{DryRun: data.DryRun, Force: ctx.Query("force") == "true"}
+	scopes := make(map[schema.GroupVersionResource]*pruneScope)
+	applied := make(map[string]struct{})
+	for _, item := range objs {
+		result := m.applyObject(ctx, item, opts, scopes)
+		if len(result.Error) != 0 {
+			data.Failed++
+		} else {
+			applied[objectKey(item.Object.GroupVersionKind().GroupKind(), result.Namespace, result.Name)] = struct{}{}
+		}
+		data.Objects = append(data.Objects, result)
+	}
+
+	if pruneSelector != nil && data.Failed == 0 {
+		for _, result := range m.prune(ctx, pruneSelector, scopes, applied, opts.DryRun) {
+			if len(result.Error) != 0 {
+				data.Failed++
+			}
+			data.Objects = append(data.Objects, result)
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (m *ManifestLogic) applyObject(ctx context.Context, item *apply.ManifestObject, opts apply.Options, scopes map[schema.GroupVersionResource]*pruneScope) *ManifestObjectResult {
+	obj := item.Object
+	result := &ManifestObjectResult{Index: item.Index, Kind: obj.GetKind(), Name: obj.GetName()}
+	mapping, err := m.Applier.Prepare(obj)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Namespace = obj.GetNamespace()
+
+	scope, ok := scopes[mapping.Resource]
+	if !ok {
+		scope = &pruneScope{mapping: mapping, namespaces: make(map[string]struct{})}
+		scopes[mapping.Resource] = scope
+	}
+	scope.namespaces[obj.GetNamespace()] = struct{}{}
+
+	// Apply会修改对象，保留一份提交的内容用于失败时的diff
+	desired := obj.DeepCopy()
+	applyResult, err := m.Applier.Apply(ctx, obj, opts)
+	if err != nil {
+		m.Log.Error(err, "apply manifest object err", "kind", result.Kind, "namespace", result.Namespace, "name", result.Name)
+		result.Error, result.Conflicts = err.Error(), apply.Conflicts(err)
+		// 例如依赖的namespace在dry-run时尚未创建，diff退化为与提交的对象比较
+		live, _ := m.Applier.Live(ctx, mapping, desired)
+		result.Diff, _ = apply.Diff(live, desired)
+		return result
+	}
+	result.Operation = applyResult.Operation
+	if result.Diff, err = apply.Diff(applyResult.Live, applyResult.Object); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// prune 删除manifest涉及的资源类型和namespace中，匹配selector但不在manifest中的对象
+func (m *ManifestLogic) prune(ctx context.Context, selector labels.Selector, scopes map[schema.GroupVersionResource]*pruneScope, applied map[string]struct{}, dryRun bool) []*ManifestObjectResult {
+	propagation := metav1.DeletePropagationBackground
+	options := metav1.DeleteOptions{PropagationPolicy: &propagation}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+
+	var results []*ManifestObjectResult
+	for _, scope := range scopes {
+		for ns := range scope.namespaces {
+			list, err := m.Applier.ResourceInterface(scope.mapping, ns).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				m.Log.Error(err, "list prune candidates err", "gvr", scope.mapping.Resource.String(), "namespace", ns)
+				results = append(results, &ManifestObjectResult{Index: -1, Kind: scope.mapping.GroupVersionKind.Kind, Namespace: ns, Operation: operationPruned, Error: err.Error()})
+				continue
+			}
+			for i := range list.Items {
+				live := &list.Items[i]
+				if _, ok := applied[objectKey(scope.mapping.GroupVersionKind.GroupKind(), live.GetNamespace(), live.GetName())]; ok {
+					continue
+				}
+				result := &ManifestObjectResult{Index: -1, Kind: live.GetKind(), Namespace: live.GetNamespace(), Name: live.GetName(), Operation: operationPruned}
+				result.Diff, _ = apply.Diff(live, nil)
+				if err := m.Applier.ResourceInterface(scope.mapping, live.GetNamespace()).Delete(ctx, live.GetName(), options); err != nil {
+					m.Log.Error(err, "prune object err", "kind", result.Kind, "namespace", result.Namespace, "name", result.Name)
+					result.Error = err.Error()
+				}
+				results = append(results, result)
+			}
+		}
+	}
+	return results
+}
+
+func objectKey(gk schema.GroupKind, namespace, name string) string {
+	return gk.String() + "/" + namespace + "/" + name
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/prometheus"
+)
+
+type MetricsLogic struct {
+	Log          logr.Logger
+	Prometheus   *prometheus.Client
+	Config       *prometheus.Config
+	NodeInformer cache.SharedIndexInformer
+}
+
+type NodeMetricsReq struct {
+	// Metrics 逗号分隔的指标名，为空时查询所有配置的指标
+	Metrics string `json:"metrics" form:"metrics"`
+	// Start End 为unix时间戳(秒)，默认最近一小时
+	Start int64  `json:"start" form:"start"`
+	End   int64  `json:"end" form:"end"`
+	Step  string `json:"step" form:"step"`
+}
+
+func NewMetricsLogic(log logr.Logger, conf *prometheus.Config, nodeInformer cache.SharedIndexInformer) *MetricsLogic {
+	logic := &MetricsLogic{
+		Log:          log.WithName("MetricsLogic"),
+		Config:       conf,
+		NodeInformer: nodeInformer,
+	}
+	if len(conf.Address) != 0 {
+		logic.Prometheus = prometheus.NewClient(conf.Address, conf.Timeout.Duration)
+	}
+	return logic
+}
+
+func (m *MetricsLogic) NodeMetricsHistory(ctx *gin.Context) {
+	name := ctx.Param("node")
+	if len(name) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "request parameter error"})
+		return
+	}
+	if m.Prometheus == nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"msg": "prometheus is not configured"})
+		return
+	}
+
+	var req NodeMetricsReq
+	if err := ctx.BindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	end := time.Now()
+	if req.End != 0 {
+		end = time.Unix(req.End, 0)
+	}
+	start := end.Add(-time.Hour)
+	if req.Start != 0 {
+		start = time.Unix(req.Start, 0)
+	}
+	if !start.Before(end) {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "start must be before end"})
+		return
+	}
+	step := time.Minute
+	if len(req.Step) != 0 {
+		var err error
+		if step, err = time.ParseDuration(req.Step); err != nil || step <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "invalid step"})
+			return
+		}
+	}
+
+	obj, exists, err := m.NodeInformer.GetStore().GetByKey(name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": comm.NodeNotFoundErr.Error()})
+		return
+	}
+	node := obj.(*v1.Node)
+
+	queryData := prometheus.QueryData{Node: node.Name, Range: rateRange(step)}
+	for _, address := range node.Status.Addresses {
+		if address.Type == v1.NodeInternalIP {
+			queryData.InternalIP = address.Address
+		}
+	}
+
+	var metricNames []string
+	if len(req.Metrics) != 0 {
+		metricNames = strings.Split(req.Metrics, ",")
+	} else {
+		for metric := range m.Config.Queries {
+			metricNames = append(metricNames, metric)
+		}
+		sort.Strings(metricNames)
+	}
+
+	data := make(map[string][]*prometheus.Series, len(metricNames))
+	for _, metric := range metricNames {
+		query, err := m.Config.Render(metric, queryData)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+			return
+		}
+		series, err := m.Prometheus.QueryRange(ctx, query, start, end, step)
+		if err != nil {
+			m.Log.Error(err, "prometheus query range err", "metric", metric, "query", query)
+			ctx.JSON(http.StatusBadGateway, gin.H{"msg": fmt.Sprintf("query %s: %s", metric, err.Error())})
+			return
+		}
+		data[metric] = series
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// rateRange rate的窗口至少要覆盖几个采集周期，否则会出现空值
+func rateRange(step time.Duration) string {
+	window := 4 * step
+	if window < 5*time.Minute {
+		window = 5 * time.Minute
+	}
+	return strconv.FormatInt(int64(window.Seconds()), 10) + "s"
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	eresource "easy-k8s/pkg/k8s/resource"
+	"easy-k8s/pkg/nstemplate"
+)
+
+type NamespaceLogic struct {
+	Log                   logr.Logger
+	DynamicClient         dynamic.Interface
+	Authorizer            *Authorizer
+	Template              *nstemplate.Template
+	NamespaceInformer     cache.SharedIndexInformer
+	PodInformer           cache.SharedIndexInformer
+	ResourceQuotaInformer cache.SharedIndexInformer
+	LimitRangeInformer    cache.SharedIndexInformer
+}
+
+type NamespaceListData struct {
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Age       string            `json:"age"`
+	Pods      map[string]int    `json:"pods"`
+	Requests  map[string]string `json:"requests"`
+	Limits    map[string]string `json:"limits"`
+	HasQuota  bool              `json:"hasQuota"`
+	HasLimits bool              `json:"hasLimits"`
+}
+
+type NamespaceDetailData struct {
+	*NamespaceListData
+	Annotations map[string]string    `json:"annotations,omitempty"`
+	Quotas      []*ResourceQuotaData `json:"quotas"`
+	LimitRanges []*LimitRangeData    `json:"limitRanges"`
+}
+
+type ResourceQuotaData struct {
+	Name      string               `json:"name"`
+	Scopes    []string             `json:"scopes,omitempty"`
+	Resources []*QuotaResourceData `json:"resources"`
+}
+
+type QuotaResourceData struct {
+	Resource string `json:"resource"`
+	Used     string `json:"used"`
+	Hard     string `json:"hard"`
+	Percent  int64  `json:"percent"`
+}
+
+type LimitRangeData struct {
+	Name   string                `json:"name"`
+	Limits []*LimitRangeItemData `json:"limits"`
+}
+
+type LimitRangeItemData struct {
+	Type                 string            `json:"type"`
+	Default              map[string]string `json:"default,omitempty"`
+	DefaultRequest       map[string]string `json:"defaultRequest,omitempty"`
+	Min                  map[string]string `json:"min,omitempty"`
+	Max                  map[string]string `json:"max,omitempty"`
+	MaxLimitRequestRatio map[string]string `json:"maxLimitRequestRatio,omitempty"`
+}
+
+type NamespaceCreateReq struct {
+	Name string `json:"name"`
+	Team string `json:"team"`
+}
+
+// NamespaceObjectResult 创建或删除的每个对象的结果
+type NamespaceObjectResult struct {
+	Kind      string         `json:"kind"`
+	Name      string         `json:"name"`
+	Namespace string         `json:"namespace,omitempty"`
+	Result    string         `json:"result"`
+	Error     string         `json:"error,omitempty"`
+	Object    map[string]any `json:"object,omitempty"`
+}
+
+// 不允许通过easy-k8s删除的namespace
+var protectedNamespaces = map[string]struct{}{
+	metav1.NamespaceDefault: {},
+	metav1.NamespaceSystem:  {},
+	metav1.NamespacePublic:  {},
+	v1.NamespaceNodeLease:   {},
+}
+
+var namespaceTemplateGVRs = map[string]schema.GroupVersionResource{
+	"Namespace":     comm.NamespaceGVR,
+	"ResourceQuota": comm.ResourceQuotaGVR,
+	"LimitRange":    comm.LimitRangeGVR,
+	"NetworkPolicy": comm.NetworkPolicyGVR,
+	"RoleBinding":   comm.RoleBindingGVR,
+}
+
+func NewNamespaceLogic(log logr.Logger, dynamicClient dynamic.Interface, authorizer *Authorizer, template *nstemplate.Template, namespaceInformer, podInformer, resourceQuotaInformer, limitRangeInformer cache.SharedIndexInformer) *NamespaceLogic {
+	return &NamespaceLogic{
+		Log:                   log.WithName("NamespaceLogic"),
+		DynamicClient:         dynamicClient,
+		Authorizer:            authorizer,
+		Template:              template,
+		NamespaceInformer:     namespaceInformer,
+		PodInformer:           podInformer,
+		ResourceQuotaInformer: resourceQuotaInformer,
+		LimitRangeInformer:    limitRangeInformer,
+	}
+}
+
+func (n *NamespaceLogic) NamespaceList(ctx *gin.Context) {
+	var data []*NamespaceListData
+	for _, obj := range n.NamespaceInformer.GetStore().List() {
+		row, err := n.namespaceData(obj.(*v1.Namespace))
+		if err != nil {
+			n.Log.Error(err, "get namespace data err")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		data = append(data, row)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (n *NamespaceLogic) NamespaceDetail(ctx *gin.Context) {
+	name := ctx.Param("ns")
+	obj, exists, err := n.NamespaceInformer.GetStore().GetByKey(name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": "namespace not found"})
+		return
+	}
+	namespace := obj.(*v1.Namespace)
+
+	row, err := n.namespaceData(namespace)
+	if err != nil {
+		n.Log.Error(err, "get namespace data err")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	data := &NamespaceDetailData{
+		NamespaceListData: row,
+		Annotations:       namespace.Annotations,
+		Quotas:            []*ResourceQuotaData{},
+		LimitRanges:       []*LimitRangeData{},
+	}
+
+	quotas, err := n.ResourceQuotaInformer.GetIndexer().ByIndex(cache.NamespaceIndex, name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	for _, obj := range quotas {
+		quota := obj.(*v1.ResourceQuota)
+		quotaData := &ResourceQuotaData{Name: quota.Name}
+		for _, scope := range quota.Spec.Scopes {
+			quotaData.Scopes = append(quotaData.Scopes, string(scope))
+		}
+		// 包括 requests.nvidia.com/gpu 等扩展资源的配额
+		for resourceName, hard := range quota.Status.Hard {
+			used := quota.Status.Used[resourceName]
+			quotaData.Resources = append(quotaData.Resources, &QuotaResourceData{
+				Resource: string(resourceName),
+				Used:     used.String(),
+				Hard:     hard.String(),
+				Percent:  quotaPercent(used, hard),
+			})
+		}
+		sort.Slice(quotaData.Resources, func(i, j int) bool {
+			return quotaData.Resources[i].Resource < quotaData.Resources[j].Resource
+		})
+		data.Quotas = append(data.Quotas, quotaData)
+	}
+
+	limitRanges, err := n.LimitRangeInformer.GetIndexer().ByIndex(cache.NamespaceIndex, name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	for _, obj := range limitRanges {
+		limitRange := obj.(*v1.LimitRange)
+		limitData := &LimitRangeData{Name: limitRange.Name}
+		for _, item := range limitRange.Spec.Limits {
+			limitData.Limits = append(limitData.Limits, &LimitRangeItemData{
+				Type:                 string(item.Type),
+				Default:              resourceListData(item.Default),
+				DefaultRequest:       resourceListData(item.DefaultRequest),
+				Min:                  resourceListData(item.Min),
+				Max:                  resourceListData(item.Max),
+				MaxLimitRequestRatio: resourceListData(item.MaxLimitRequestRatio),
+			})
+		}
+		data.LimitRanges = append(data.LimitRanges, limitData)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// NamespaceCreate 按模板一次性创建namespace及其ResourceQuota、LimitRange、NetworkPolicy、RoleBinding，
+// 遇到错误时停止并返回已经创建的对象，dryRun=true时只做服务端校验不会真正创建
+func (n *NamespaceLogic) NamespaceCreate(ctx *gin.Context) {
+	if !n.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	var req NamespaceCreateReq
+	if err := ctx.BindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	params := nstemplate.Params{Name: req.Name, Team: req.Team}
+	if err := params.Validate(); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	dryRun := ctx.Query("dryRun") == "true"
+
+	spec, err := n.Template.Render(params)
+	if err != nil {
+		n.Log.Error(err, "render namespace template err")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	objs, err := spec.Objects(req.Name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+
+	options := metav1.CreateOptions{}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	var results []*NamespaceObjectResult
+	var created []int
+	var failed bool
+	var createErr error
+	for i, obj := range objs {
+		result := &NamespaceObjectResult{Kind: obj.GetKind(), Name: obj.GetName(), Namespace: obj.GetNamespace()}
+		results = append(results, result)
+		if failed {
+			result.Result = "skipped"
+			continue
+		}
+		if dryRun {
+			result.Object = obj.Object
+		}
+		// dry-run时namespace并不存在，namespace内的对象无法在服务端校验，只返回渲染结果
+		if dryRun && i != 0 {
+			result.Result = "dryRun"
+			continue
+		}
+
+		gvr := namespaceTemplateGVRs[obj.GetKind()]
+		var resourceClient dynamic.ResourceInterface = n.DynamicClient.Resource(gvr)
+		if len(obj.GetNamespace()) != 0 {
+			resourceClient = n.DynamicClient.Resource(gvr).Namespace(obj.GetNamespace())
+		}
+		if _, createErr = resourceClient.Create(ctx, obj, options); createErr != nil {
+			n.Log.Error(createErr, "create namespace object err", "kind", result.Kind, "name", result.Name)
+			result.Result, result.Error = "failed", createErr.Error()
+			failed = true
+			continue
+		}
+		if dryRun {
+			result.Result = "dryRun"
+		} else {
+			result.Result = "created"
+			created = append(created, i)
+		}
+	}
+
+	code := http.StatusOK
+	if failed {
+		n.rollback(ctx, objs, created, results)
+		code = http.StatusInternalServerError
+		if apierrors.IsAlreadyExists(createErr) {
+			code = http.StatusConflict
+		}
+	}
+	ctx.JSON(code, gin.H{"data": gin.H{"dryRun": dryRun, "objects": results}})
+}
+
+// rollback 创建失败时按创建的逆序删除已创建的对象，避免留下不完整的namespace
+func (n *NamespaceLogic) rollback(ctx *gin.Context, objs []*unstructured.Unstructured, created []int, results []*NamespaceObjectResult) {
+	for i := len(created) - 1; i >= 0; i-- {
+		obj, result := objs[created[i]], results[created[i]]
+		gvr := namespaceTemplateGVRs[obj.GetKind()]
+		var resourceClient dynamic.ResourceInterface = n.DynamicClient.Resource(gvr)
+		if len(obj.GetNamespace()) != 0 {
+			resourceClient = n.DynamicClient.Resource(gvr).Namespace(obj.GetNamespace())
+		}
+		if err := resourceClient.Delete(ctx, obj.GetName(), metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			n.Log.Error(err, "rollback namespace object err", "kind", result.Kind, "name", result.Name)
+			result.Error = "rollback failed: " + err.Error()
+			continue
+		}
+		result.Result = "rolledBack"
+	}
+}
+
+// NamespaceDelete 删除namespace，系统namespace不允许删除
+func (n *NamespaceLogic) NamespaceDelete(ctx *gin.Context) {
+	if !n.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	name := ctx.Param("ns")
+	if _, ok := protectedNamespaces[name]; ok || strings.HasPrefix(name, "kube-") {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "namespace " + name + " is protected"})
+		return
+	}
+	dryRun := ctx.Query("dryRun") == "true"
+
+	options := metav1.DeleteOptions{}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	result := &NamespaceObjectResult{Kind: "Namespace", Name: name, Result: "deleted"}
+	if dryRun {
+		result.Result = "dryRun"
+	}
+	if err := n.DynamicClient.Resource(comm.NamespaceGVR).Delete(ctx, name, options); err != nil {
+		n.Log.Error(err, "delete namespace err", "name", name)
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+
+	// namespace删除是异步的，返回其中仍存在的对象数量供参考
+	pods, _ := n.PodInformer.GetIndexer().ByIndex(cache.NamespaceIndex, name)
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"dryRun": dryRun, "objects": []*NamespaceObjectResult{result}, "remainingPods": len(pods)}})
+}
+
+// namespaceData 统计namespace下各状态的pod数量，以及未结束pod的requests/limits之和
+func (n *NamespaceLogic) namespaceData(namespace *v1.Namespace) (*NamespaceListData, error) {
+	data := &NamespaceListData{
+		Name:   namespace.Name,
+		Status: string(namespace.Status.Phase),
+		Labels: namespace.Labels,
+		Age:    translateTimestampSince(namespace.CreationTimestamp),
+		Pods:   map[string]int{},
+	}
+
+	pods, err := n.PodInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	reqs, limits := v1.ResourceList{}, v1.ResourceList{}
+	for _, obj := range pods {
+		pod := obj.(*v1.Pod)
+		data.Pods[string(pod.Status.Phase)]++
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		podReqs, podLimits := eresource.PodRequestsAndLimits(pod)
+		addResourceList(reqs, podReqs)
+		addResourceList(limits, podLimits)
+	}
+	data.Requests = resourceListData(reqs)
+	data.Limits = resourceListData(limits)
+
+	quotas, err := n.ResourceQuotaInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	limitRanges, err := n.LimitRangeInformer.GetIndexer().ByIndex(cache.NamespaceIndex, namespace.Name)
+	if err != nil {
+		return nil, err
+	}
+	data.HasQuota = len(quotas) != 0
+	data.HasLimits = len(limitRanges) != 0
+	return data, nil
+}
+
+func quotaPercent(used, hard resource.Quantity) int64 {
+	if hard.MilliValue() == 0 {
+		return 0
+	}
+	return int64(float64(used.MilliValue()) / float64(hard.MilliValue()) * 100)
+}
@@ -0,0 +1,605 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/informerfactory"
+)
+
+type NetworkLogic struct {
+	Log                   logr.Logger
+	PodInformer           cache.SharedIndexInformer
+	NamespaceInformer     cache.SharedIndexInformer
+	ServiceInformer       cache.SharedIndexInformer
+	EndpointSliceInformer cache.SharedIndexInformer
+	IngressInformer       cache.SharedIndexInformer
+	NetworkPolicyInformer cache.SharedIndexInformer
+}
+
+type ServiceListData struct {
+	Name              string             `json:"name"`
+	Namespace         string             `json:"namespace"`
+	Type              string             `json:"type"`
+	ClusterIP         string             `json:"clusterIP"`
+	ExternalIPs       []string           `json:"externalIPs,omitempty"`
+	LoadBalancer      []string           `json:"loadBalancer,omitempty"`
+	Ports             []*ServicePortData `json:"ports"`
+	Selector          map[string]string  `json:"selector,omitempty"`
+	Pods              []*ServicePodData  `json:"pods"`
+	ReadyEndpoints    int                `json:"readyEndpoints"`
+	NotReadyEndpoints int                `json:"notReadyEndpoints"`
+	Age               string             `json:"age"`
+}
+
+type ServicePortData struct {
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol"`
+	Port       int32  `json:"port"`
+	TargetPort string `json:"targetPort"`
+	NodePort   int32  `json:"nodePort,omitempty"`
+}
+
+type ServicePodData struct {
+	Name     string `json:"name"`
+	Ip       string `json:"ip"`
+	NodeName string `json:"nodeName"`
+	Ready    bool   `json:"ready"`
+}
+
+type IngressListData struct {
+	Name           string              `json:"name"`
+	Namespace      string              `json:"namespace"`
+	IngressClass   string              `json:"ingressClass,omitempty"`
+	Addresses      []string            `json:"addresses,omitempty"`
+	TLSHosts       []string            `json:"tlsHosts,omitempty"`
+	DefaultBackend *IngressBackendData `json:"defaultBackend,omitempty"`
+	Rules          []*IngressRuleData  `json:"rules"`
+	Age            string              `json:"age"`
+}
+
+type IngressRuleData struct {
+	Host     string              `json:"host"`
+	Path     string              `json:"path"`
+	PathType string              `json:"pathType,omitempty"`
+	Backend  *IngressBackendData `json:"backend"`
+}
+
+type IngressBackendData struct {
+	ServiceName    string `json:"serviceName,omitempty"`
+	ServicePort    string `json:"servicePort,omitempty"`
+	ServiceFound   bool   `json:"serviceFound"`
+	ReadyEndpoints int    `json:"readyEndpoints"`
+	// Resource 后端为其他资源时的 Kind/name
+	Resource string `json:"resource,omitempty"`
+}
+
+type PodNetworkData struct {
+	Name        string               `json:"name"`
+	Namespace   string               `json:"namespace"`
+	PodIPs      []string             `json:"podIPs"`
+	HostIP      string               `json:"hostIP"`
+	HostNetwork bool                 `json:"hostNetwork"`
+	Ports       []*ContainerPortData `json:"ports"`
+	Services    []*PodServiceData    `json:"services"`
+	Policies    []*PolicyData        `json:"policies"`
+	Ingress     *AllowedPeerSummary  `json:"ingress"`
+	Egress      *AllowedPeerSummary  `json:"egress"`
+}
+
+type ContainerPortData struct {
+	Container     string `json:"container"`
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+	HostPort      int32  `json:"hostPort,omitempty"`
+	Protocol      string `json:"protocol"`
+}
+
+type PodServiceData struct {
+	Name      string             `json:"name"`
+	Type      string             `json:"type"`
+	ClusterIP string             `json:"clusterIP"`
+	Ports     []*ServicePortData `json:"ports"`
+}
+
+type PolicyData struct {
+	Name        string            `json:"name"`
+	PolicyTypes []string          `json:"policyTypes"`
+	Ingress     []*PolicyRuleData `json:"ingress,omitempty"`
+	Egress      []*PolicyRuleData `json:"egress,omitempty"`
+}
+
+type PolicyRuleData struct {
+	// Ports 为空表示所有端口
+	Ports []string `json:"ports,omitempty"`
+	// Peers 为空表示所有来源/目标
+	Peers []*PolicyPeerData `json:"peers"`
+}
+
+type PolicyPeerData struct {
+	IPBlock           string   `json:"ipBlock,omitempty"`
+	Except            []string `json:"except,omitempty"`
+	NamespaceSelector string   `json:"namespaceSelector,omitempty"`
+	// PodSelector 为空表示namespace下的所有pod
+	PodSelector string `json:"podSelector,omitempty"`
+	// Namespaces Pods 根据当前的namespace和pod标签计算出的匹配结果
+	Namespaces []string `json:"namespaces,omitempty"`
+	Pods       int      `json:"pods"`
+}
+
+// AllowedPeerSummary 某个方向上允许的peer汇总，没有策略选中该pod时不隔离，允许所有流量
+type AllowedPeerSummary struct {
+	Isolated bool `json:"isolated"`
+	AllowAll bool `json:"allowAll"`
+	// Peers 允许的pod，格式为 namespace 或 namespace:podSelector
+	Peers    []string `json:"peers,omitempty"`
+	IPBlocks []string `json:"ipBlocks,omitempty"`
+	Ports    []string `json:"ports,omitempty"`
+	Policies []string `json:"policies"`
+}
+
+func NewNetworkLogic(log logr.Logger, podInformer, namespaceInformer, serviceInformer, endpointSliceInformer, ingressInformer, networkPolicyInformer cache.SharedIndexInformer) *NetworkLogic {
+	return &NetworkLogic{
+		Log:                   log.WithName("NetworkLogic"),
+		PodInformer:           podInformer,
+		NamespaceInformer:     namespaceInformer,
+		ServiceInformer:       serviceInformer,
+		EndpointSliceInformer: endpointSliceInformer,
+		IngressInformer:       ingressInformer,
+		NetworkPolicyInformer: networkPolicyInformer,
+	}
+}
+
+func (n *NetworkLogic) ServiceList(ctx *gin.Context) {
+	ns := ctx.Param("ns")
+	if ns == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "namespace is empty"})
+		return
+	}
+	objs, err := n.ServiceInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		n.Log.Error(err, "get service list by namespace")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := make([]*ServiceListData, 0, len(objs))
+	for _, obj := range objs {
+		svc := obj.(*v1.Service)
+		row := &ServiceListData{
+			Name:        svc.Name,
+			Namespace:   svc.Namespace,
+			Type:        string(svc.Spec.Type),
+			ClusterIP:   svc.Spec.ClusterIP,
+			ExternalIPs: svc.Spec.ExternalIPs,
+			Selector:    svc.Spec.Selector,
+			Age:         translateTimestampSince(svc.CreationTimestamp),
+		}
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if len(ingress.IP) != 0 {
+				row.LoadBalancer = append(row.LoadBalancer, ingress.IP)
+			} else {
+				row.LoadBalancer = append(row.LoadBalancer, ingress.Hostname)
+			}
+		}
+		for _, port := range svc.Spec.Ports {
+			row.Ports = append(row.Ports, &ServicePortData{
+				Name:       port.Name,
+				Protocol:   string(port.Protocol),
+				Port:       port.Port,
+				TargetPort: port.TargetPort.String(),
+				NodePort:   port.NodePort,
+			})
+		}
+		row.Pods, err = n.servicePods(svc)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		row.ReadyEndpoints, row.NotReadyEndpoints, err = n.endpointCount(svc.Namespace, svc.Name)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		data = append(data, row)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (n *NetworkLogic) IngressList(ctx *gin.Context) {
+	ns := ctx.Param("ns")
+	if ns == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "namespace is empty"})
+		return
+	}
+	objs, err := n.IngressInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		n.Log.Error(err, "get ingress list by namespace")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := make([]*IngressListData, 0, len(objs))
+	for _, obj := range objs {
+		ingress := obj.(*networkingv1.Ingress)
+		row := &IngressListData{
+			Name:      ingress.Name,
+			Namespace: ingress.Namespace,
+			Rules:     []*IngressRuleData{},
+			Age:       translateTimestampSince(ingress.CreationTimestamp),
+		}
+		if ingress.Spec.IngressClassName != nil {
+			row.IngressClass = *ingress.Spec.IngressClassName
+		}
+		for _, lb := range ingress.Status.LoadBalancer.Ingress {
+			if len(lb.IP) != 0 {
+				row.Addresses = append(row.Addresses, lb.IP)
+			} else {
+				row.Addresses = append(row.Addresses, lb.Hostname)
+			}
+		}
+		for _, tls := range ingress.Spec.TLS {
+			row.TLSHosts = append(row.TLSHosts, tls.Hosts...)
+		}
+		if ingress.Spec.DefaultBackend != nil {
+			row.DefaultBackend = n.ingressBackend(ingress.Namespace, ingress.Spec.DefaultBackend)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				ruleData := &IngressRuleData{
+					Host:    rule.Host,
+					Path:    path.Path,
+					Backend: n.ingressBackend(ingress.Namespace, &path.Backend),
+				}
+				if path.PathType != nil {
+					ruleData.PathType = string(*path.PathType)
+				}
+				row.Rules = append(row.Rules, ruleData)
+			}
+		}
+		data = append(data, row)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (n *NetworkLogic) ingressBackend(namespace string, backend *networkingv1.IngressBackend) *IngressBackendData {
+	data := &IngressBackendData{}
+	if backend.Resource != nil {
+		data.Resource = backend.Resource.Kind + "/" + backend.Resource.Name
+		return data
+	}
+	if backend.Service == nil {
+		return data
+	}
+
+	data.ServiceName = backend.Service.Name
+	if len(backend.Service.Port.Name) != 0 {
+		data.ServicePort = backend.Service.Port.Name
+	} else {
+		data.ServicePort = fmt.Sprint(backend.Service.Port.Number)
+	}
+	_, exists, err := n.ServiceInformer.GetStore().GetByKey(namespace + "/" + data.ServiceName)
+	if err != nil {
+		n.Log.Error(err, "get service by key")
+	}
+	data.ServiceFound = exists
+	if exists {
+		data.ReadyEndpoints, _, _ = n.endpointCount(namespace, data.ServiceName)
+	}
+	return data
+}
+
+// servicePods 通过service的selector从pod informer中查找后端pod，没有selector的service返回空
+func (n *NetworkLogic) servicePods(svc *v1.Service) ([]*ServicePodData, error) {
+	data := []*ServicePodData{}
+	if len(svc.Spec.Selector) == 0 {
+		return data, nil
+	}
+	objs, err := n.PodInformer.GetIndexer().ByIndex(cache.NamespaceIndex, svc.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	selector := labels.SelectorFromSet(svc.Spec.Selector)
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		data = append(data, &ServicePodData{
+			Name:     pod.Name,
+			Ip:       pod.Status.PodIP,
+			NodeName: pod.Spec.NodeName,
+			Ready:    podReady(pod),
+		})
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	return data, nil
+}
+
+// endpointCount 统计service所有EndpointSlice中就绪和未就绪的endpoint，双栈的同一个pod只计一次
+func (n *NetworkLogic) endpointCount(namespace, name string) (ready, notReady int, err error) {
+	objs, err := n.EndpointSliceInformer.GetIndexer().ByIndex(informerfactory.ServiceNameIndex, namespace+"/"+name)
+	if err != nil {
+		return 0, 0, err
+	}
+	seen := make(map[string]struct{})
+	for _, obj := range objs {
+		slice := obj.(*discoveryv1.EndpointSlice)
+		for _, endpoint := range slice.Endpoints {
+			var key string
+			if endpoint.TargetRef != nil {
+				key = endpoint.TargetRef.Kind + "/" + endpoint.TargetRef.Name
+			} else if len(endpoint.Addresses) != 0 {
+				key = endpoint.Addresses[0]
+			}
+			if _, ok := seen[key]; ok && len(key) != 0 {
+				continue
+			}
+			seen[key] = struct{}{}
+			// Ready为空时按照API约定视为就绪
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready++
+			} else {
+				notReady++
+			}
+		}
+	}
+	return ready, notReady, nil
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func (n *NetworkLogic) PodNetwork(ctx *gin.Context) {
+	ns, name := ctx.Param("ns"), ctx.Param("name")
+	obj, exists, err := n.PodInformer.GetStore().GetByKey(ns + "/" + name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	if !exists {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": comm.PodNotFoundErr.Error()})
+		return
+	}
+	pod := obj.(*v1.Pod)
+
+	data := &PodNetworkData{
+		Name:        pod.Name,
+		Namespace:   pod.Namespace,
+		HostIP:      pod.Status.HostIP,
+		HostNetwork: pod.Spec.HostNetwork,
+		Ports:       []*ContainerPortData{},
+		Services:    []*PodServiceData{},
+		Policies:    []*PolicyData{},
+	}
+	for _, ip := range pod.Status.PodIPs {
+		data.PodIPs = append(data.PodIPs, ip.IP)
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			data.Ports = append(data.Ports, &ContainerPortData{
+				Container:     container.Name,
+				Name:          port.Name,
+				ContainerPort: port.ContainerPort,
+				HostPort:      port.HostPort,
+				Protocol:      string(port.Protocol),
+			})
+		}
+	}
+
+	services, err := n.ServiceInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	for _, obj := range services {
+		svc := obj.(*v1.Service)
+		if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		row := &PodServiceData{Name: svc.Name, Type: string(svc.Spec.Type), ClusterIP: svc.Spec.ClusterIP}
+		for _, port := range svc.Spec.Ports {
+			row.Ports = append(row.Ports, &ServicePortData{
+				Name:       port.Name,
+				Protocol:   string(port.Protocol),
+				Port:       port.Port,
+				TargetPort: port.TargetPort.String(),
+				NodePort:   port.NodePort,
+			})
+		}
+		data.Services = append(data.Services, row)
+	}
+
+	policies, err := n.NetworkPolicyInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	data.Ingress = &AllowedPeerSummary{AllowAll: true, Policies: []string{}}
+	data.Egress = &AllowedPeerSummary{AllowAll: true, Policies: []string{}}
+	for _, obj := range policies {
+		policy := obj.(*networkingv1.NetworkPolicy)
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		row := &PolicyData{Name: policy.Name}
+		ingress, egress := policyTypes(policy)
+		if ingress {
+			row.PolicyTypes = append(row.PolicyTypes, string(networkingv1.PolicyTypeIngress))
+			for _, rule := range policy.Spec.Ingress {
+				row.Ingress = append(row.Ingress, n.policyRule(policy.Namespace, rule.Ports, rule.From))
+			}
+			data.Ingress.add(policy.Name, row.Ingress)
+		}
+		if egress {
+			row.PolicyTypes = append(row.PolicyTypes, string(networkingv1.PolicyTypeEgress))
+			for _, rule := range policy.Spec.Egress {
+				row.Egress = append(row.Egress, n.policyRule(policy.Namespace, rule.Ports, rule.To))
+			}
+			data.Egress.add(policy.Name, row.Egress)
+		}
+		data.Policies = append(data.Policies, row)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// policyRule 解析一条ingress/egress规则，并在本地计算peer匹配到的namespace和pod
+func (n *NetworkLogic) policyRule(namespace string, ports []networkingv1.NetworkPolicyPort, peers []networkingv1.NetworkPolicyPeer) *PolicyRuleData {
+	data := &PolicyRuleData{Peers: []*PolicyPeerData{}}
+	for _, port := range ports {
+		protocol := string(v1.ProtocolTCP)
+		if port.Protocol != nil {
+			protocol = string(*port.Protocol)
+		}
+		value := "all"
+		if port.Port != nil {
+			value = port.Port.String()
+			if port.EndPort != nil {
+				value = fmt.Sprintf("%s-%d", value, *port.EndPort)
+			}
+		}
+		data.Ports = append(data.Ports, protocol+"/"+value)
+	}
+
+	for _, peer := range peers {
+		row := &PolicyPeerData{}
+		if peer.IPBlock != nil {
+			row.IPBlock = peer.IPBlock.CIDR
+			row.Except = peer.IPBlock.Except
+			data.Peers = append(data.Peers, row)
+			continue
+		}
+
+		namespaces := []string{namespace}
+		if peer.NamespaceSelector != nil {
+			row.NamespaceSelector = metav1.FormatLabelSelector(peer.NamespaceSelector)
+			namespaces = n.selectNamespaces(peer.NamespaceSelector)
+		}
+		podSelector := labels.Everything()
+		if peer.PodSelector != nil {
+			if selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector); err == nil {
+				podSelector = selector
+			} else {
+				podSelector = labels.Nothing()
+			}
+			if !podSelector.Empty() {
+				row.PodSelector = metav1.FormatLabelSelector(peer.PodSelector)
+			}
+		}
+		row.Namespaces = namespaces
+		for _, ns := range namespaces {
+			objs, err := n.PodInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+			if err != nil {
+				n.Log.Error(err, "get pod list by namespace")
+				continue
+			}
+			for _, obj := range objs {
+				if podSelector.Matches(labels.Set(obj.(*v1.Pod).Labels)) {
+					row.Pods++
+				}
+			}
+		}
+		data.Peers = append(data.Peers, row)
+	}
+	return data
+}
+
+func (n *NetworkLogic) selectNamespaces(labelSelector *metav1.LabelSelector) []string {
+	data := []string{}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return data
+	}
+	for _, obj := range n.NamespaceInformer.GetStore().List() {
+		namespace := obj.(*v1.Namespace)
+		if selector.Matches(labels.Set(namespace.Labels)) {
+			data = append(data, namespace.Name)
+		}
+	}
+	sort.Strings(data)
+	return data
+}
+
+// policyTypes 未指定policyTypes时，ingress始终生效，egress只在配置了egress规则时生效
+func policyTypes(policy *networkingv1.NetworkPolicy) (ingress, egress bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) != 0
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return
+}
+
+// add 合并选中该pod的一条策略，pod一旦被某个策略选中就只允许规则中的peer
+func (s *AllowedPeerSummary) add(policy string, rules []*PolicyRuleData) {
+	if !s.Isolated {
+		s.Isolated, s.AllowAll = true, false
+	}
+	s.Policies = append(s.Policies, policy)
+
+	for _, rule := range rules {
+		if len(rule.Ports) == 0 {
+			s.Ports = appendUnique(s.Ports, "all")
+		}
+		for _, port := range rule.Ports {
+			s.Ports = appendUnique(s.Ports, port)
+		}
+		if len(rule.Peers) == 0 {
+			s.AllowAll = true
+			continue
+		}
+		// 当前没有匹配到pod的peer同样放行之后创建的pod，不能跳过
+		for _, peer := range rule.Peers {
+			if len(peer.IPBlock) != 0 {
+				s.IPBlocks = appendUnique(s.IPBlocks, peer.IPBlock)
+				continue
+			}
+			for _, ns := range peer.Namespaces {
+				if len(peer.PodSelector) != 0 {
+					ns += ":" + peer.PodSelector
+				}
+				s.Peers = appendUnique(s.Peers, ns)
+			}
+		}
+	}
+	sort.Strings(s.Peers)
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, item := range list {
+		if item == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodNetworkAllowedPeers(t *testing.T) {
+	pod := testPod("default", "db", "node-1")
+	pod.Labels = map[string]string{"app": "db"}
+	web := testPod("default", "web", "node-1")
+	web.Labels = map[string]string{"app": "web"}
+	monitor := testPod("monitor", "prometheus", "node-1")
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+					// 当前没有匹配的pod
+					{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "job"}}},
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "ops"}}, PodSelector: &metav1.LabelSelector{}},
+				},
+			}},
+		},
+	}
+
+	namespaceIndexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	n := NewNetworkLogic(logr.Discard(),
+		newTestInformer(t, &v1.Pod{}, testPodIndexers, pod, web, monitor),
+		newTestInformer(t, &v1.Namespace{}, cache.Indexers{},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+			&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "monitor", Labels: map[string]string{"team": "ops"}}},
+		),
+		newTestInformer(t, &v1.Service{}, namespaceIndexers),
+		nil,
+		nil,
+		newTestInformer(t, &networkingv1.NetworkPolicy{}, namespaceIndexers, policy),
+	)
+
+	var data PodNetworkData
+	serveTest(t, n.PodNetwork, "/podNetwork/:ns/:name", "/podNetwork/default/db", &data)
+
+	if !data.Ingress.Isolated || data.Ingress.AllowAll {
+		t.Errorf("ingress = %+v, want isolated", data.Ingress)
+	}
+	want := []string{"default:app=job", "default:app=web", "monitor"}
+	if !reflect.DeepEqual(data.Ingress.Peers, want) {
+		t.Errorf("ingress peers = %v, want %v", data.Ingress.Peers, want)
+	}
+	if data.Egress.Isolated || !data.Egress.AllowAll {
+		t.Errorf("egress = %+v, want allow all", data.Egress)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+
+	"easy-k8s/pkg/nodehistory"
+)
+
+type NodeHistoryLogic struct {
+	Log      logr.Logger
+	Recorder *nodehistory.Recorder
+}
+
+type NodeHistoryReq struct {
+	// Since Until 为unix时间戳(秒)，Window 为最近一段时间，如 24h，与Since同时指定时以Window为准
+	Since  int64  `json:"since" form:"since"`
+	Until  int64  `json:"until" form:"until"`
+	Window string `json:"window" form:"window"`
+	// Type 只返回指定类型的变更，如 status、label、taint、cordon、condition
+	Type string `json:"type" form:"type"`
+}
+
+func NewNodeHistoryLogic(log logr.Logger, recorder *nodehistory.Recorder) *NodeHistoryLogic {
+	return &NodeHistoryLogic{
+		Log:      log.WithName("NodeHistoryLogic"),
+		Recorder: recorder,
+	}
+}
+
+// NodeHistory GET /nodeHistory/:node 节点的状态、condition、label、taint和cordon变更时间线，按时间倒序
+func (n *NodeHistoryLogic) NodeHistory(ctx *gin.Context) {
+	name := ctx.Param("node")
+	var req NodeHistoryReq
+	if err := ctx.BindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	var since, until time.Time
+	if req.Since > 0 {
+		since = time.Unix(req.Since, 0)
+	}
+	if req.Until > 0 {
+		until = time.Unix(req.Until, 0)
+	}
+	if len(req.Window) != 0 {
+		window, err := time.ParseDuration(req.Window)
+		if err != nil || window <= 0 {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": "invalid window"})
+			return
+		}
+		since = time.Now().Add(-window)
+	}
+
+	entries, err := n.Recorder.Timeline(name, since, until)
+	if err != nil {
+		n.Log.Error(err, "query node history err", "node", name)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	if len(req.Type) != 0 {
+		filtered := []*nodehistory.Entry{}
+		for _, entry := range entries {
+			if entry.Type == req.Type {
+				filtered = append(filtered, entry)
+			}
+		}
+		entries = filtered
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": entries})
+}
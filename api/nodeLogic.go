@@ -1,11 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
@@ -17,12 +20,15 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/metrics"
 	eresource "easy-k8s/pkg/k8s/resource"
 )
 
 type NodeLogic struct {
 	Log           logr.Logger
 	DynamicClient dynamic.Interface
+	Metrics       metrics.Provider
 	NodeInformer  cache.SharedIndexInformer
 	PodInformer   cache.SharedIndexInformer
 }
@@ -38,6 +44,8 @@ var displayFileds = map[string]struct{}{
 	"kernelVersion":    {},
 	"containerRuntime": {},
 	"gpuProduct":       {},
+	"usage":            {},
+	"conditions":       {},
 }
 
 type NodeListData struct {
@@ -52,6 +60,34 @@ type NodeListData struct {
 	KernelVersion    string `json:"kernelVersion,omitempty"`
 	ContainerRuntime string `json:"containerRuntime,omitempty"`
 	GpuProduct       string `json:"gpuProduct,omitempty"`
+	CpuUsage         string `json:"cpuUsage,omitempty"`
+	MemoryUsage      string `json:"memoryUsage,omitempty"`
+	// Problems 异常的condition类型，如 MemoryPressure、KernelDeadlock
+	Problems   []string             `json:"problems,omitempty"`
+	Conditions []*NodeConditionData `json:"conditions,omitempty"`
+}
+
+type NodeConditionData struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	Reason             string `json:"reason,omitempty"`
+	Message            string `json:"message,omitempty"`
+	LastHeartbeatTime  string `json:"lastHeartbeatTime,omitempty"`
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+	// Since 距离上次状态变化的时间
+	Since   string `json:"since,omitempty"`
+	Problem bool   `json:"problem"`
+}
+
+// NodeHealthData 异常节点，Score越高问题越严重
+type NodeHealthData struct {
+	Name          string               `json:"name"`
+	Status        string               `json:"status"`
+	Unschedulable bool                 `json:"unschedulable"`
+	Score         int                  `json:"score"`
+	Problems      []*NodeConditionData `json:"problems"`
+	// lastTransition 最早出现的问题的时间，分数相同时持续时间长的排在前面
+	lastTransition time.Time
 }
 
 type NodeListReq struct {
@@ -60,6 +96,51 @@ type NodeListReq struct {
 	Name     string `json:"name" form:"name"`
 }
 
+type NodeResourceReq struct {
+	// OnlyRunning 只统计Running状态的pod，默认与调度器一致统计所有已绑定且未结束的pod
+	OnlyRunning bool `json:"onlyRunning" form:"onlyRunning"`
+}
+
+type NodeResourceData struct {
+	GpuProduct    string                       `json:"gpuProduct,omitempty"`
+	Capacity      map[string]string            `json:"capacity"`
+	Allocatable   map[string]string            `json:"allocatable"`
+	Allocated     []*ResourceAllocation        `json:"allocated"`
+	Usage         []*ResourceUsage             `json:"usage,omitempty"`
+	PhaseRequests map[string]map[string]string `json:"phaseRequests"`
+	Pods          *NodePodsCount               `json:"pods"`
+	PodResources  []*PodResourceData           `json:"podResources"`
+}
+
+// ResourceAllocation 与kubectl describe node中Allocated resources一致，百分比相对于节点的allocatable
+type ResourceAllocation struct {
+	Resource        string `json:"resource"`
+	Requests        string `json:"requests"`
+	RequestsPercent int64  `json:"requestsPercent"`
+	Limits          string `json:"limits"`
+	LimitsPercent   int64  `json:"limitsPercent"`
+}
+
+// ResourceUsage metrics-server采集的实际用量，百分比相对于节点的allocatable
+type ResourceUsage struct {
+	Resource string `json:"resource"`
+	Usage    string `json:"usage"`
+	Percent  int64  `json:"percent"`
+}
+
+type NodePodsCount struct {
+	Count   int   `json:"count"`
+	Max     int64 `json:"max"`
+	Percent int64 `json:"percent"`
+}
+
+type PodResourceData struct {
+	Name      string                `json:"name"`
+	Namespace string                `json:"namespace"`
+	Status    string                `json:"status"`
+	Resources []*ResourceAllocation `json:"resources"`
+}
+
 type NodeLabelPatchReq struct {
 	Labels []*struct {
 		Op     string `json:"op"`
@@ -69,10 +150,13 @@ type NodeLabelPatchReq struct {
 	} `json:"labels"`
 }
 
-func NewNodeLogic(log logr.Logger, dynamicClient dynamic.Interface, nodeInformer, podInformer cache.SharedIndexInformer) *NodeLogic {
+var nodeResourceNames = []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory, v1.ResourceEphemeralStorage, comm.LabelNVIDIA, v1.ResourcePods}
+
+func NewNodeLogic(log logr.Logger, dynamicClient dynamic.Interface, metricsProvider metrics.Provider, nodeInformer, podInformer cache.SharedIndexInformer) *NodeLogic {
 	return &NodeLogic{
 		Log:           log.WithName("NodeLogic"),
 		DynamicClient: dynamicClient,
+		Metrics:       metricsProvider,
 		NodeInformer:  nodeInformer,
 		PodInformer:   podInformer,
 	}
@@ -106,6 +190,11 @@ func (n *NodeLogic) GetNodeList(ctx *gin.Context) {
 		return
 	}
 
+	var nodeUsage map[string]v1.ResourceList
+	if _, ok := displayFileds["usage"]; ok {
+		nodeUsage = n.nodeMetrics(ctx)
+	}
+
 	for _, obj := range n.NodeInformer.GetStore().List() {
 		node := obj.(*v1.Node)
 
@@ -161,18 +250,22 @@ func (n *NodeLogic) GetNodeList(ctx *gin.Context) {
 			}
 		}
 		if _, ok := displayFileds["status"]; ok {
-			for _, condition := range node.Status.Conditions {
-				if condition.Type == v1.NodeReady {
-					if condition.Status == v1.ConditionTrue {
-						data.Status = "Ready"
-					} else if condition.Status == v1.ConditionUnknown {
-						data.Status = "NotReady"
-					} else {
-						data.Status = "Unknown"
-					}
-				}
+			data.Status = nodeStatus(node)
+		}
+		// Problems 不受展示字段控制，始终返回
+		conditions := nodeConditions(node)
+		for _, condition := range conditions {
+			if condition.Problem {
+				data.Problems = append(data.Problems, condition.Type)
 			}
 		}
+		if _, ok := displayFileds["conditions"]; ok {
+			data.Conditions = conditions
+		}
+		if usage, ok := nodeUsage[node.Name]; ok {
+			data.CpuUsage = usage.Cpu().String()
+			data.MemoryUsage = usage.Memory().String()
+		}
 		if _, ok := displayFileds["roles"]; ok {
 			roles := []string{}
 			for k, v := range node.Labels {
@@ -288,6 +381,11 @@ func (n *NodeLogic) NodeResource(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "request parameter error"})
 		return
 	}
+	var req NodeResourceReq
+	if err := ctx.BindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
 	node, err := n.getNodeByName(name)
 	if err != nil {
 		if errors.Is(err, comm.NodeNotFoundErr) {
@@ -298,41 +396,77 @@ func (n *NodeLogic) NodeResource(ctx *gin.Context) {
 		return
 	}
 
-	total := make(map[string]string)
-	var product string
-	cpuAllocatable := node.Status.Allocatable[v1.ResourceCPU]
-	memAllocatable := node.Status.Allocatable[v1.ResourceMemory]
-	total["cpu"] = cpuAllocatable.String()
-	total["memory"] = memAllocatable.String()
-	if _, ok := node.Status.Allocatable[comm.LabelNVIDIA]; ok {
-		for key := range node.GetLabels() {
-			if strings.HasPrefix(key, "osgalaxy.io-gpu-nvidia.com") {
-				product = strings.Split(key, "/")[1]
-			}
-		}
-		nvAllocate := node.Status.Allocatable[comm.LabelNVIDIA]
-		total[product] = nvAllocate.String()
-	}
-	used := make(map[string]string)
-	objs, err := n.PodInformer.GetIndexer().ByIndex("nodeNameIdx", node.GetName())
+	objs, err := n.PodInformer.GetIndexer().ByIndex(informerfactory.NodeNameIndex, node.GetName())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
 		return
 	}
 
-	reqs, _ := n.getPodsTotalRequestsAndLimits(objs)
-	cpuReq := reqs[v1.ResourceCPU]
-	memReq := reqs[v1.ResourceMemory]
-	used["cpu"] = cpuReq.String()
-	used["memory"] = memReq.String()
-	if _, ok := reqs[comm.LabelNVIDIA]; ok {
-		nvReq := reqs[comm.LabelNVIDIA]
-		used[product] = nvReq.String()
+	allocatable := node.Status.Allocatable
+	data := &NodeResourceData{
+		GpuProduct:  comm.NodeGpuProduct(node.GetLabels()),
+		Capacity:    make(map[string]string),
+		Allocatable: make(map[string]string),
+	}
+	for _, resourceName := range nodeResourceNames {
+		if value, ok := node.Status.Capacity[resourceName]; ok {
+			data.Capacity[string(resourceName)] = value.String()
+		}
+		if value, ok := allocatable[resourceName]; ok {
+			data.Allocatable[string(resourceName)] = value.String()
+		}
+	}
+
+	reqs, limits := n.getPodsTotalRequestsAndLimits(objs, req.OnlyRunning)
+	data.Allocated = resourceAllocations(reqs, limits, allocatable)
+
+	if usage, ok := n.nodeMetrics(ctx)[node.Name]; ok {
+		for _, resourceName := range []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory} {
+			value := usage[resourceName]
+			data.Usage = append(data.Usage, &ResourceUsage{
+				Resource: string(resourceName),
+				Usage:    value.String(),
+				Percent:  quantityPercent(resourceName, value, allocatable[resourceName]),
+			})
+		}
+	}
+
+	phasePods := make(map[v1.PodPhase][]any)
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if podOccupiesNode(pod, req.OnlyRunning) {
+			phasePods[pod.Status.Phase] = append(phasePods[pod.Status.Phase], obj)
+		}
+	}
+	data.PhaseRequests = make(map[string]map[string]string)
+	for phase, pods := range phasePods {
+		phaseReqs, _ := n.getPodsTotalRequestsAndLimits(pods, req.OnlyRunning)
+		data.PhaseRequests[string(phase)] = make(map[string]string)
+		for name, value := range phaseReqs {
+			data.PhaseRequests[string(phase)][string(name)] = value.String()
+		}
+	}
+
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		if !podOccupiesNode(pod, req.OnlyRunning) {
+			continue
+		}
+		podReqs, podLimits := eresource.PodRequestsAndLimits(pod)
+		data.PodResources = append(data.PodResources, &PodResourceData{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Status:    string(pod.Status.Phase),
+			Resources: resourceAllocations(podReqs, podLimits, allocatable),
+		})
+	}
+
+	maxPods := allocatable[v1.ResourcePods]
+	data.Pods = &NodePodsCount{Count: len(data.PodResources), Max: maxPods.Value()}
+	if maxPods.Value() != 0 {
+		data.Pods.Percent = int64(float64(len(data.PodResources)) / float64(maxPods.Value()) * 100)
 	}
-	ctx.JSON(http.StatusOK, gin.H{"data": map[string]map[string]string{
-		"total": total,
-		"used":  used,
-	}})
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
 }
 
 func (n *NodeLogic) NodePodList(ctx *gin.Context) {
@@ -353,7 +487,7 @@ func (n *NodeLogic) NodePodList(ctx *gin.Context) {
 		return
 	}
 
-	objs, err := n.PodInformer.GetIndexer().ByIndex("nodeNameIdx", node.GetName())
+	objs, err := n.PodInformer.GetIndexer().ByIndex(informerfactory.NodeNameIndex, node.GetName())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
 		return
@@ -405,6 +539,61 @@ func (n *NodeLogic) NodePodList(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"data": data})
 }
 
+// NodeConditions 返回节点的所有condition，包括node-problem-detector上报的自定义condition
+func (n *NodeLogic) NodeConditions(ctx *gin.Context) {
+	node, err := n.getNodeByName(ctx.Param("node"))
+	if err != nil {
+		if errors.Is(err, comm.NodeNotFoundErr) {
+			ctx.JSON(http.StatusNotFound, gin.H{"msg": "node not found"})
+			return
+		}
+		n.Log.Error(err, "get node err")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": gin.H{"status": nodeStatus(node), "unschedulable": node.Spec.Unschedulable, "conditions": nodeConditions(node)}})
+}
+
+// UnhealthyNodes 返回存在异常condition或不可调度的节点，按问题严重程度排序
+func (n *NodeLogic) UnhealthyNodes(ctx *gin.Context) {
+	rows := []*NodeHealthData{}
+	for _, obj := range n.NodeInformer.GetStore().List() {
+		node := obj.(*v1.Node)
+		data := &NodeHealthData{Name: node.Name, Status: nodeStatus(node), Unschedulable: node.Spec.Unschedulable, Problems: []*NodeConditionData{}}
+		if node.Spec.Unschedulable {
+			data.Score += unschedulableScore
+		}
+		for i, condition := range nodeConditions(node) {
+			if !condition.Problem {
+				continue
+			}
+			data.Score += conditionScore(v1.NodeConditionType(condition.Type))
+			data.Problems = append(data.Problems, condition)
+			// 缺少Ready时补充的condition没有对应的时间
+			if i >= len(node.Status.Conditions) {
+				continue
+			}
+			transition := node.Status.Conditions[i].LastTransitionTime.Time
+			if data.lastTransition.IsZero() || transition.Before(data.lastTransition) {
+				data.lastTransition = transition
+			}
+		}
+		if data.Score > 0 {
+			rows = append(rows, data)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Score != rows[j].Score {
+			return rows[i].Score > rows[j].Score
+		}
+		if !rows[i].lastTransition.Equal(rows[j].lastTransition) {
+			return rows[i].lastTransition.Before(rows[j].lastTransition)
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	ctx.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
 func (n *NodeLogic) getNodeByName(name string) (*v1.Node, error) {
 	obj, exists, err := n.NodeInformer.GetStore().GetByKey(name)
 	if err != nil {
@@ -419,11 +608,23 @@ func (n *NodeLogic) getNodeByName(name string) (*v1.Node, error) {
 	return obj.(*v1.Node), nil
 }
 
-func (n *NodeLogic) getPodsTotalRequestsAndLimits(podList []any) (reqs map[v1.ResourceName]resource.Quantity, limits map[v1.ResourceName]resource.Quantity) {
+// nodeMetrics metrics-server不可用时返回nil，只是不展示实际用量
+func (n *NodeLogic) nodeMetrics(ctx context.Context) map[string]v1.ResourceList {
+	data, err := n.Metrics.NodeMetrics(ctx)
+	if err != nil {
+		if !errors.Is(err, metrics.ErrMetricsUnavailable) {
+			n.Log.Error(err, "get node metrics err")
+		}
+		return nil
+	}
+	return data
+}
+
+func (n *NodeLogic) getPodsTotalRequestsAndLimits(podList []any, onlyRunning bool) (reqs map[v1.ResourceName]resource.Quantity, limits map[v1.ResourceName]resource.Quantity) {
 	reqs, limits = map[v1.ResourceName]resource.Quantity{}, map[v1.ResourceName]resource.Quantity{}
 	for _, obj := range podList {
 		pod := obj.(*v1.Pod)
-		if pod.Status.Phase != v1.PodRunning {
+		if !podOccupiesNode(pod, onlyRunning) {
 			continue
 		}
 		podReqs, podLimits := eresource.PodRequestsAndLimits(pod)
@@ -446,3 +647,126 @@ func (n *NodeLogic) getPodsTotalRequestsAndLimits(podList []any) (reqs map[v1.Re
 	}
 	return
 }
+
+// podOccupiesNode 调度器会把已绑定到节点且未结束的pod(包括Pending、ContainerCreating)计入节点已分配资源
+func podOccupiesNode(pod *v1.Pod, onlyRunning bool) bool {
+	if onlyRunning {
+		return pod.Status.Phase == v1.PodRunning
+	}
+	return len(pod.Spec.NodeName) != 0 && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed
+}
+
+func resourceAllocations(reqs, limits, allocatable v1.ResourceList) []*ResourceAllocation {
+	var data []*ResourceAllocation
+	for _, name := range nodeResourceNames {
+		if name == v1.ResourcePods {
+			continue
+		}
+		_, hasReq := reqs[name]
+		_, hasLimit := limits[name]
+		if _, ok := allocatable[name]; !ok && !hasReq && !hasLimit {
+			continue
+		}
+		req, limit, total := reqs[name], limits[name], allocatable[name]
+		data = append(data, &ResourceAllocation{
+			Resource:        string(name),
+			Requests:        req.String(),
+			RequestsPercent: quantityPercent(name, req, total),
+			Limits:          limit.String(),
+			LimitsPercent:   quantityPercent(name, limit, total),
+		})
+	}
+	return data
+}
+
+func quantityPercent(name v1.ResourceName, used, total resource.Quantity) int64 {
+	if name == v1.ResourceCPU {
+		if total.MilliValue() == 0 {
+			return 0
+		}
+		return int64(float64(used.MilliValue()) / float64(total.MilliValue()) * 100)
+	}
+	if total.Value() == 0 {
+		return 0
+	}
+	return int64(float64(used.Value()) / float64(total.Value()) * 100)
+}
+
+// 异常节点排序的权重
+const (
+	notReadyScore           = 100
+	networkUnavailableScore = 50
+	pressureScore           = 20
+	customConditionScore    = 10
+	unschedulableScore      = 5
+)
+
+// nodeStatus 与kubectl一致，Ready为False时为NotReady，Unknown表示kubelet失联
+func nodeStatus(node *v1.Node) string {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != v1.NodeReady {
+			continue
+		}
+		switch condition.Status {
+		case v1.ConditionTrue:
+			return "Ready"
+		case v1.ConditionFalse:
+			return "NotReady"
+		default:
+			return "Unknown"
+		}
+	}
+	return "Unknown"
+}
+
+// nodeConditions Ready不为True，或其他condition(包括node-problem-detector的自定义condition)为True时视为异常，
+// kubelet没有上报Ready时在末尾补充一条状态为Unknown的Ready
+func nodeConditions(node *v1.Node) []*NodeConditionData {
+	conditions := make([]*NodeConditionData, 0, len(node.Status.Conditions)+1)
+	hasReady := false
+	for _, condition := range node.Status.Conditions {
+		hasReady = hasReady || condition.Type == v1.NodeReady
+		data := &NodeConditionData{
+			Type:    string(condition.Type),
+			Status:  string(condition.Status),
+			Reason:  condition.Reason,
+			Message: condition.Message,
+		}
+		if !condition.LastHeartbeatTime.IsZero() {
+			data.LastHeartbeatTime = condition.LastHeartbeatTime.Format(time.RFC3339)
+		}
+		if !condition.LastTransitionTime.IsZero() {
+			data.LastTransitionTime = condition.LastTransitionTime.Format(time.RFC3339)
+			data.Since = translateTimestampSince(condition.LastTransitionTime)
+		}
+		if condition.Type == v1.NodeReady {
+			data.Problem = condition.Status != v1.ConditionTrue
+		} else {
+			data.Problem = condition.Status == v1.ConditionTrue
+		}
+		conditions = append(conditions, data)
+	}
+	if !hasReady {
+		conditions = append(conditions, &NodeConditionData{
+			Type:    string(v1.NodeReady),
+			Status:  string(v1.ConditionUnknown),
+			Reason:  "NoReadyCondition",
+			Message: "kubelet has not reported the Ready condition",
+			Problem: true,
+		})
+	}
+	return conditions
+}
+
+func conditionScore(conditionType v1.NodeConditionType) int {
+	switch conditionType {
+	case v1.NodeReady:
+		return notReadyScore
+	case v1.NodeNetworkUnavailable:
+		return networkUnavailableScore
+	case v1.NodeMemoryPressure, v1.NodeDiskPressure, v1.NodePIDPressure:
+		return pressureScore
+	default:
+		return customConditionScore
+	}
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/metrics"
+)
+
+// newTestInformer 不启动的informer，对象直接写入indexer
+func newTestInformer(t *testing.T, example runtime.Object, indexers cache.Indexers, objs ...runtime.Object) cache.SharedIndexInformer {
+	t.Helper()
+	informer := cache.NewSharedIndexInformer(nil, example, 0, indexers)
+	for _, obj := range objs {
+		if err := informer.GetIndexer().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return informer
+}
+
+var testPodIndexers = cache.Indexers{
+	cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	informerfactory.NodeNameIndex: func(obj any) ([]string, error) {
+		return []string{obj.(*v1.Pod).Spec.NodeName}, nil
+	},
+}
+
+func testNode(name string) *v1.Node {
+	allocatable := v1.ResourceList{
+		v1.ResourceCPU:    resource.MustParse("4"),
+		v1.ResourceMemory: resource.MustParse("8Gi"),
+		v1.ResourcePods:   resource.MustParse("110"),
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Capacity:    allocatable,
+			Allocatable: allocatable,
+			Conditions:  []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func testPod(namespace, name, node string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{{
+				Name: "app",
+				Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("500m"),
+					v1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+			}},
+		},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+// serveTest 调用handler并把响应中的data解析到out
+func serveTest(t *testing.T, handler gin.HandlerFunc, pattern, target string, out any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET(pattern, handler)
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	body := struct {
+		Data any `json:"data"`
+	}{Data: out}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestNodeLogic(t *testing.T, provider metrics.Provider) *NodeLogic {
+	nodeInformer := newTestInformer(t, &v1.Node{}, cache.Indexers{}, testNode("node-1"))
+	podInformer := newTestInformer(t, &v1.Pod{}, testPodIndexers, testPod("default", "web", "node-1"))
+	return NewNodeLogic(logr.Discard(), nil, provider, nodeInformer, podInformer)
+}
+
+func TestNodeResourceUsage(t *testing.T) {
+	provider := &metrics.FakeProvider{Nodes: map[string]v1.ResourceList{
+		"node-1": {
+			v1.ResourceCPU:    resource.MustParse("1"),
+			v1.ResourceMemory: resource.MustParse("2Gi"),
+		},
+	}}
+	n := newTestNodeLogic(t, provider)
+
+	var data NodeResourceData
+	serveTest(t, n.NodeResource, "/nodeResource/:node", "/nodeResource/node-1", &data)
+
+	want := map[string]ResourceUsage{
+		"cpu":    {Resource: "cpu", Usage: "1", Percent: 25},
+		"memory": {Resource: "memory", Usage: "2Gi", Percent: 25},
+	}
+	if len(data.Usage) != len(want) {
+		t.Fatalf("usage = %+v, want %d entries", data.Usage, len(want))
+	}
+	for _, usage := range data.Usage {
+		if *usage != want[usage.Resource] {
+			t.Errorf("usage %s = %+v, want %+v", usage.Resource, *usage, want[usage.Resource])
+		}
+	}
+}
+
+func TestNodeResourceMetricsUnavailable(t *testing.T) {
+	n := newTestNodeLogic(t, &metrics.FakeProvider{Err: metrics.ErrMetricsUnavailable})
+
+	var data NodeResourceData
+	serveTest(t, n.NodeResource, "/nodeResource/:node", "/nodeResource/node-1", &data)
+
+	if len(data.Usage) != 0 {
+		t.Errorf("usage = %+v, want none when metrics api is unavailable", data.Usage)
+	}
+	if data.Pods == nil || data.Pods.Count != 1 {
+		t.Errorf("pods = %+v, want 1 pod", data.Pods)
+	}
+}
+
+func TestUnhealthyNodesMissingReady(t *testing.T) {
+	ready := testNode("ready")
+	noReady := testNode("no-ready")
+	noReady.Status.Conditions = nil
+	nodeInformer := newTestInformer(t, &v1.Node{}, cache.Indexers{}, ready, noReady)
+	n := NewNodeLogic(logr.Discard(), nil, &metrics.FakeProvider{}, nodeInformer, newTestInformer(t, &v1.Pod{}, testPodIndexers))
+
+	var data []*NodeHealthData
+	serveTest(t, n.UnhealthyNodes, "/nodes/unhealthy", "/nodes/unhealthy", &data)
+
+	if len(data) != 1 || data[0].Name != "no-ready" || data[0].Score != notReadyScore {
+		t.Fatalf("unhealthy nodes = %+v, want no-ready with score %d", data, notReadyScore)
+	}
+	if data[0].Status != "Unknown" || len(data[0].Problems) != 1 || data[0].Problems[0].Type != string(v1.NodeReady) {
+		t.Errorf("node = %+v, want Unknown with a Ready problem", data[0])
+	}
+}
+
+func TestNodeListProblemsWithoutConditionsField(t *testing.T) {
+	saved := displayFileds
+	displayFileds = map[string]struct{}{}
+	defer func() { displayFileds = saved }()
+
+	node := testNode("node-1")
+	node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionTrue})
+	nodeInformer := newTestInformer(t, &v1.Node{}, cache.Indexers{}, node)
+	n := NewNodeLogic(logr.Discard(), nil, &metrics.FakeProvider{}, nodeInformer, newTestInformer(t, &v1.Pod{}, testPodIndexers))
+
+	var data []*NodeListData
+	serveTest(t, n.GetNodeList, "/nodeList", "/nodeList", &data)
+
+	if len(data) != 1 || len(data[0].Problems) != 1 || data[0].Problems[0] != string(v1.NodeDiskPressure) {
+		t.Fatalf("nodes = %+v, want DiskPressure problem", data)
+	}
+	if len(data[0].Conditions) != 0 {
+		t.Errorf("conditions = %+v, want none when not displayed", data[0].Conditions)
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -8,43 +10,140 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 
 	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/metrics"
+	"easy-k8s/pkg/k8s/reference"
 )
 
 type PodLogic struct {
 	Log           logr.Logger
 	DynamicClient dynamic.Interface
+	Metrics       metrics.Provider
 	NodeInformer  cache.SharedIndexInformer
 	PodInformer   cache.SharedIndexInformer
+	Dependency    *PodDependencyInformers
+}
+
+// PodDependencyInformers 解析pod引用的对象时使用的informer
+type PodDependencyInformers struct {
+	ConfigMap             cache.SharedIndexInformer
+	Secret                cache.SharedIndexInformer
+	PersistentVolumeClaim cache.SharedIndexInformer
+	PersistentVolume      cache.SharedIndexInformer
+	StorageClass          cache.SharedIndexInformer
+	ServiceAccount        cache.SharedIndexInformer
 }
 
 type PodVolumeData struct {
-	Name          string `json:"name,omitempty"`
-	ResourceName  string `json:"resourceName,omitempty"`
-	Option        *bool  `json:"option,omitempty"`
-	HostPath      string `json:"hostPath,omitempty"`
-	HostPathType  string `json:"hostPathType,omitempty"`
-	ReadOnly      *bool  `json:"readOnly,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	ResourceName string   `json:"resourceName,omitempty"`
+	Option       *bool    `json:"option,omitempty"`
+	HostPath     string   `json:"hostPath,omitempty"`
+	HostPathType string   `json:"hostPathType,omitempty"`
+	ReadOnly     *bool    `json:"readOnly,omitempty"`
+	VolumeType   string   `json:"volumeType,omitempty"`
+	Medium       string   `json:"medium,omitempty"`
+	SizeLimit    string   `json:"sizeLimit,omitempty"`
+	Driver       string   `json:"driver,omitempty"`
+	FsType       string   `json:"fsType,omitempty"`
+	Server       string   `json:"server,omitempty"`
+	Path         string   `json:"path,omitempty"`
+	StorageClass string   `json:"storageClass,omitempty"`
+	AccessModes  []string `json:"accessModes,omitempty"`
+	Storage      string   `json:"storage,omitempty"`
+	Image        string   `json:"image,omitempty"`
+	PullPolicy   string   `json:"pullPolicy,omitempty"`
+	// Items downwardAPI卷中的文件
+	Items []*VolumeItem `json:"items,omitempty"`
+	// Sources projected卷中的ConfigMap/Secret/ServiceAccountToken等来源
+	Sources []*ProjectedSourceData `json:"sources,omitempty"`
+	// Attributes csi卷的volumeAttributes，或其他in-tree卷的关键字段
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Mounts 所有挂载了该卷的容器
+	Mounts []*VolumeMountData `json:"mounts"`
+}
+
+type VolumeMountData struct {
+	ContainerName string `json:"containerName"`
+	ContainerType string `json:"containerType"`
+	MountPath     string `json:"mountPath"`
+	SubPath       string `json:"subPath,omitempty"`
+	SubPathExpr   string `json:"subPathExpr,omitempty"`
+	ReadOnly      bool   `json:"readOnly"`
+	Propagation   string `json:"propagation,omitempty"`
+}
+
+type ProjectedSourceData struct {
+	SourceType        string        `json:"sourceType"`
+	ResourceName      string        `json:"resourceName,omitempty"`
+	Option            *bool         `json:"option,omitempty"`
+	Audience          string        `json:"audience,omitempty"`
+	ExpirationSeconds *int64        `json:"expirationSeconds,omitempty"`
+	Path              string        `json:"path,omitempty"`
+	Items             []*VolumeItem `json:"items,omitempty"`
+}
+
+type VolumeItem struct {
+	Path          string `json:"path"`
+	FieldPath     string `json:"fieldPath,omitempty"`
+	Resource      string `json:"resource,omitempty"`
 	ContainerName string `json:"containerName,omitempty"`
-	MountPath     string `json:"mountPath,omitempty"`
-	VolumeType    string `json:"volumeType,omitempty"`
 }
 
 type PodAssociatedResourcesRsp struct {
-	PodName    string                    `json:"podName"`
-	VolumeData map[string]*PodVolumeData `json:"volumeData"`
+	PodName      string                    `json:"podName"`
+	VolumeData   map[string]*PodVolumeData `json:"volumeData"`
+	Dependencies *PodDependencies          `json:"dependencies"`
+}
+
+type PodDependencies struct {
+	ServiceAccount         *DependencyData      `json:"serviceAccount"`
+	ConfigMaps             []*DependencyData    `json:"configMaps"`
+	Secrets                []*DependencyData    `json:"secrets"`
+	PersistentVolumeClaims []*PVCDependencyData `json:"persistentVolumeClaims"`
+	// Missing 不存在且不是optional的对象，格式为 Kind/name
+	Missing []string `json:"missing"`
+}
+
+type DependencyData struct {
+	Name  string `json:"name"`
+	Found bool   `json:"found"`
+	// Optional 所有引用都是optional时为true
+	Optional bool `json:"optional"`
+	// Missing 对象不存在且存在非optional的引用，pod会因此无法启动
+	Missing bool `json:"missing"`
+	// MissingKeys env中引用但对象中不存在的key
+	MissingKeys []string               `json:"missingKeys,omitempty"`
+	References  []*reference.Reference `json:"references"`
+}
+
+type PVCDependencyData struct {
+	*DependencyData
+	Phase             string   `json:"phase,omitempty"`
+	Capacity          string   `json:"capacity,omitempty"`
+	AccessModes       []string `json:"accessModes,omitempty"`
+	VolumeName        string   `json:"volumeName,omitempty"`
+	VolumeFound       bool     `json:"volumeFound"`
+	VolumePhase       string   `json:"volumePhase,omitempty"`
+	ReclaimPolicy     string   `json:"reclaimPolicy,omitempty"`
+	StorageClass      string   `json:"storageClass,omitempty"`
+	StorageClassFound bool     `json:"storageClassFound"`
+	Provisioner       string   `json:"provisioner,omitempty"`
 }
 
-func NewPodLogic(log logr.Logger, dynamicClient dynamic.Interface, nodeInformer, podInformer cache.SharedIndexInformer) *PodLogic {
+func NewPodLogic(log logr.Logger, dynamicClient dynamic.Interface, metricsProvider metrics.Provider, nodeInformer, podInformer cache.SharedIndexInformer, dependency *PodDependencyInformers) *PodLogic {
 	return &PodLogic{
 		Log:           log.WithName("PodLogic"),
 		DynamicClient: dynamicClient,
+		Metrics:       metricsProvider,
 		NodeInformer:  nodeInformer,
 		PodInformer:   podInformer,
+		Dependency:    dependency,
 	}
 }
 
@@ -62,6 +161,8 @@ func (p *PodLogic) PodListByNs(ctx *gin.Context) {
 		return
 	}
 
+	podUsage := p.podMetrics(ctx, ns)
+
 	var data []*PodData
 	for _, obj := range objs {
 		pod := obj.(*v1.Pod)
@@ -105,6 +206,10 @@ func (p *PodLogic) PodListByNs(ctx *gin.Context) {
 			GpuProduct:  gpuProduct,
 			Status:      string(pod.Status.Phase),
 		}
+		if usage, ok := podUsage[pod.Name]; ok {
+			row.CpuUsage = usage.Cpu().String()
+			row.MemoryUsage = usage.Memory().String()
+		}
 		data = append(data, row)
 	}
 	ctx.JSON(http.StatusOK, gin.H{"data": data})
@@ -119,68 +224,401 @@ func (p *PodLogic) PodAssociatedResources(ctx *gin.Context) {
 		return
 	}
 	data := &PodAssociatedResourcesRsp{PodName: name}
-	volumeData := p.volumeData(pod.Spec.Volumes)
-	mountInfo := p.mouthInfo(pod.Spec.Containers)
-	for cname, mount := range mountInfo {
-		for mname, path := range mount {
-			if _, ok := volumeData[mname]; ok {
-				volumeData[mname].ContainerName = cname
-				volumeData[mname].MountPath = path
-			}
+	volumeData := p.volumeData(pod)
+	for _, mount := range p.mountInfo(pod) {
+		if volume, ok := volumeData[mount.VolumeName]; ok {
+			volume.Mounts = append(volume.Mounts, mount.VolumeMountData)
 		}
 	}
 	data.VolumeData = volumeData
+	data.Dependencies = p.dependencies(pod)
 	ctx.JSON(http.StatusOK, gin.H{"data": data})
 }
 
-func (p *PodLogic) mouthInfo(containers []v1.Container) map[string]map[string]string {
-	data := make(map[string]map[string]string)
-	for _, container := range containers {
-		data[container.Name] = make(map[string]string)
-		for _, mount := range container.VolumeMounts {
-			data[container.Name][mount.Name] = mount.MountPath
+type volumeMount struct {
+	VolumeName string
+	*VolumeMountData
+}
+
+// mountInfo 按init容器、容器、临时容器的顺序返回pod中所有的挂载
+func (p *PodLogic) mountInfo(pod *v1.Pod) []volumeMount {
+	var data []volumeMount
+	appendMounts := func(containerName, containerType string, mounts []v1.VolumeMount) {
+		for _, mount := range mounts {
+			row := &VolumeMountData{
+				ContainerName: containerName,
+				ContainerType: containerType,
+				MountPath:     mount.MountPath,
+				SubPath:       mount.SubPath,
+				SubPathExpr:   mount.SubPathExpr,
+				ReadOnly:      mount.ReadOnly,
+			}
+			if mount.MountPropagation != nil {
+				row.Propagation = string(*mount.MountPropagation)
+			}
+			data = append(data, volumeMount{VolumeName: mount.Name, VolumeMountData: row})
 		}
 	}
+	for _, container := range pod.Spec.InitContainers {
+		appendMounts(container.Name, comm.ContainerTypeInit, container.VolumeMounts)
+	}
+	for _, container := range pod.Spec.Containers {
+		appendMounts(container.Name, comm.ContainerTypeContainer, container.VolumeMounts)
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		appendMounts(container.Name, comm.ContainerTypeEphemeral, container.VolumeMounts)
+	}
 	return data
 }
 
-func (p *PodLogic) volumeData(volumes []v1.Volume) map[string]*PodVolumeData {
+func (p *PodLogic) volumeData(pod *v1.Pod) map[string]*PodVolumeData {
 	data := make(map[string]*PodVolumeData, 0)
-	for _, volume := range volumes {
-		if volume.ConfigMap != nil {
+	for _, volume := range pod.Spec.Volumes {
+		item := &PodVolumeData{Name: volume.Name}
+		switch {
+		case volume.ConfigMap != nil:
 			optional := volume.ConfigMap.Optional != nil && *volume.ConfigMap.Optional
-			data[volume.Name] = &PodVolumeData{
-				ResourceName: volume.ConfigMap.Name,
-				Option:       &optional,
-				VolumeType:   comm.VolumeConfigMap,
+			item.ResourceName = volume.ConfigMap.Name
+			item.Option = &optional
+			item.VolumeType = comm.VolumeConfigMap
+		case volume.Secret != nil:
+			optional := volume.Secret.Optional != nil && *volume.Secret.Optional
+			item.ResourceName = volume.Secret.SecretName
+			item.Option = &optional
+			item.VolumeType = comm.VolumeSecret
+		case volume.HostPath != nil:
+			item.HostPath = volume.HostPath.Path
+			if volume.HostPath.Type != nil {
+				item.HostPathType = string(*volume.HostPath.Type)
 			}
-			continue
+			item.VolumeType = comm.VolumeHostPath
+		case volume.PersistentVolumeClaim != nil:
+			item.ResourceName = volume.PersistentVolumeClaim.ClaimName
+			item.ReadOnly = &volume.PersistentVolumeClaim.ReadOnly
+			item.VolumeType = comm.VolumePersistentVolumeClaim
+		case volume.EmptyDir != nil:
+			item.Medium = string(volume.EmptyDir.Medium)
+			if volume.EmptyDir.SizeLimit != nil {
+				item.SizeLimit = volume.EmptyDir.SizeLimit.String()
+			}
+			item.VolumeType = comm.VolumeEmptyDir
+		case volume.Projected != nil:
+			item.Sources = projectedSources(volume.Projected.Sources)
+			item.VolumeType = comm.VolumeProjected
+		case volume.DownwardAPI != nil:
+			item.Items = downwardAPIItems(volume.DownwardAPI.Items)
+			item.VolumeType = comm.VolumeDownwardAPI
+		case volume.CSI != nil:
+			item.Driver = volume.CSI.Driver
+			item.ReadOnly = volume.CSI.ReadOnly
+			if volume.CSI.FSType != nil {
+				item.FsType = *volume.CSI.FSType
+			}
+			item.Attributes = volume.CSI.VolumeAttributes
+			item.VolumeType = comm.VolumeCSI
+		case volume.NFS != nil:
+			item.Server = volume.NFS.Server
+			item.Path = volume.NFS.Path
+			item.ReadOnly = &volume.NFS.ReadOnly
+			item.VolumeType = comm.VolumeNFS
+		case volume.Ephemeral != nil:
+			// 通用临时卷由控制器创建名为 <pod>-<volume> 的PVC
+			item.ResourceName = fmt.Sprintf("%s-%s", pod.Name, volume.Name)
+			item.VolumeType = comm.VolumeEphemeral
+			if tmpl := volume.Ephemeral.VolumeClaimTemplate; tmpl != nil {
+				if tmpl.Spec.StorageClassName != nil {
+					item.StorageClass = *tmpl.Spec.StorageClassName
+				}
+				for _, mode := range tmpl.Spec.AccessModes {
+					item.AccessModes = append(item.AccessModes, string(mode))
+				}
+				if storage, ok := tmpl.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+					item.Storage = storage.String()
+				}
+			}
+		case volume.Image != nil:
+			item.Image = volume.Image.Reference
+			item.PullPolicy = string(volume.Image.PullPolicy)
+			item.VolumeType = comm.VolumeImage
+		default:
+			item.VolumeType, item.Attributes = legacyVolumeSource(volume.VolumeSource)
 		}
-		if volume.Secret != nil {
-			optional := volume.Secret.Optional != nil && *volume.Secret.Optional
-			data[volume.Name] = &PodVolumeData{
-				ResourceName: volume.Secret.SecretName,
+		data[volume.Name] = item
+	}
+	return data
+}
+
+func projectedSources(sources []v1.VolumeProjection) []*ProjectedSourceData {
+	var data []*ProjectedSourceData
+	for _, source := range sources {
+		switch {
+		case source.ConfigMap != nil:
+			optional := source.ConfigMap.Optional != nil && *source.ConfigMap.Optional
+			data = append(data, &ProjectedSourceData{
+				SourceType:   comm.VolumeConfigMap,
+				ResourceName: source.ConfigMap.Name,
+				Option:       &optional,
+			})
+		case source.Secret != nil:
+			optional := source.Secret.Optional != nil && *source.Secret.Optional
+			data = append(data, &ProjectedSourceData{
+				SourceType:   comm.VolumeSecret,
+				ResourceName: source.Secret.Name,
 				Option:       &optional,
-				VolumeType:   comm.VolumeSecret,
+			})
+		case source.ServiceAccountToken != nil:
+			data = append(data, &ProjectedSourceData{
+				SourceType:        comm.VolumeServiceAccountToken,
+				Audience:          source.ServiceAccountToken.Audience,
+				ExpirationSeconds: source.ServiceAccountToken.ExpirationSeconds,
+				Path:              source.ServiceAccountToken.Path,
+			})
+		case source.DownwardAPI != nil:
+			data = append(data, &ProjectedSourceData{
+				SourceType: comm.VolumeDownwardAPI,
+				Items:      downwardAPIItems(source.DownwardAPI.Items),
+			})
+		case source.ClusterTrustBundle != nil:
+			row := &ProjectedSourceData{
+				SourceType: comm.VolumeClusterTrustBundle,
+				Option:     source.ClusterTrustBundle.Optional,
+				Path:       source.ClusterTrustBundle.Path,
 			}
-			continue
+			if source.ClusterTrustBundle.Name != nil {
+				row.ResourceName = *source.ClusterTrustBundle.Name
+			} else if source.ClusterTrustBundle.SignerName != nil {
+				row.ResourceName = *source.ClusterTrustBundle.SignerName
+			}
+			data = append(data, row)
+		}
+	}
+	return data
+}
+
+func downwardAPIItems(items []v1.DownwardAPIVolumeFile) []*VolumeItem {
+	var data []*VolumeItem
+	for _, item := range items {
+		row := &VolumeItem{Path: item.Path}
+		if item.FieldRef != nil {
+			row.FieldPath = item.FieldRef.FieldPath
+		}
+		if item.ResourceFieldRef != nil {
+			row.Resource = item.ResourceFieldRef.Resource
+			row.ContainerName = item.ResourceFieldRef.ContainerName
 		}
-		if volume.HostPath != nil {
-			data[volume.Name] = &PodVolumeData{
-				HostPath:     volume.HostPath.Path,
-				HostPathType: string(*volume.HostPath.Type),
-				VolumeType:   comm.VolumeHostPath,
+		data = append(data, row)
+	}
+	return data
+}
+
+// legacyVolumeSource 其他in-tree存储卷只返回类型和关键字段
+func legacyVolumeSource(source v1.VolumeSource) (string, map[string]string) {
+	switch {
+	case source.GCEPersistentDisk != nil:
+		return "gcePersistentDisk", map[string]string{"pdName": source.GCEPersistentDisk.PDName, "fsType": source.GCEPersistentDisk.FSType}
+	case source.AWSElasticBlockStore != nil:
+		return "awsElasticBlockStore", map[string]string{"volumeID": source.AWSElasticBlockStore.VolumeID, "fsType": source.AWSElasticBlockStore.FSType}
+	case source.GitRepo != nil:
+		return "gitRepo", map[string]string{"repository": source.GitRepo.Repository, "revision": source.GitRepo.Revision, "directory": source.GitRepo.Directory}
+	case source.ISCSI != nil:
+		return "iscsi", map[string]string{"targetPortal": source.ISCSI.TargetPortal, "iqn": source.ISCSI.IQN, "lun": fmt.Sprint(source.ISCSI.Lun)}
+	case source.Glusterfs != nil:
+		return "glusterfs", map[string]string{"endpoints": source.Glusterfs.EndpointsName, "path": source.Glusterfs.Path}
+	case source.RBD != nil:
+		return "rbd", map[string]string{"monitors": strings.Join(source.RBD.CephMonitors, ","), "pool": source.RBD.RBDPool, "image": source.RBD.RBDImage}
+	case source.FlexVolume != nil:
+		return "flexVolume", map[string]string{"driver": source.FlexVolume.Driver, "fsType": source.FlexVolume.FSType}
+	case source.Cinder != nil:
+		return "cinder", map[string]string{"volumeID": source.Cinder.VolumeID, "fsType": source.Cinder.FSType}
+	case source.CephFS != nil:
+		return "cephfs", map[string]string{"monitors": strings.Join(source.CephFS.Monitors, ","), "path": source.CephFS.Path}
+	case source.Flocker != nil:
+		return "flocker", map[string]string{"datasetName": source.Flocker.DatasetName, "datasetUUID": source.Flocker.DatasetUUID}
+	case source.FC != nil:
+		return "fc", map[string]string{"targetWWNs": strings.Join(source.FC.TargetWWNs, ","), "wwids": strings.Join(source.FC.WWIDs, ",")}
+	case source.AzureFile != nil:
+		return "azureFile", map[string]string{"secretName": source.AzureFile.SecretName, "shareName": source.AzureFile.ShareName}
+	case source.VsphereVolume != nil:
+		return "vsphereVolume", map[string]string{"volumePath": source.VsphereVolume.VolumePath, "fsType": source.VsphereVolume.FSType}
+	case source.Quobyte != nil:
+		return "quobyte", map[string]string{"registry": source.Quobyte.Registry, "volume": source.Quobyte.Volume}
+	case source.AzureDisk != nil:
+		return "azureDisk", map[string]string{"diskName": source.AzureDisk.DiskName, "diskURI": source.AzureDisk.DataDiskURI}
+	case source.PhotonPersistentDisk != nil:
+		return "photonPersistentDisk", map[string]string{"pdID": source.PhotonPersistentDisk.PdID, "fsType": source.PhotonPersistentDisk.FSType}
+	case source.PortworxVolume != nil:
+		return "portworxVolume", map[string]string{"volumeID": source.PortworxVolume.VolumeID, "fsType": source.PortworxVolume.FSType}
+	case source.ScaleIO != nil:
+		return "scaleIO", map[string]string{"gateway": source.ScaleIO.Gateway, "system": source.ScaleIO.System, "volumeName": source.ScaleIO.VolumeName}
+	case source.StorageOS != nil:
+		return "storageos", map[string]string{"volumeName": source.StorageOS.VolumeName, "volumeNamespace": source.StorageOS.VolumeNamespace}
+	}
+	return "unknown", nil
+}
+
+// dependencies 解析pod引用的ConfigMap、Secret、PVC、ServiceAccount，并检查引用的对象和key是否存在
+func (p *PodLogic) dependencies(pod *v1.Pod) *PodDependencies {
+	data := &PodDependencies{}
+	deps := make(map[string]*DependencyData)
+	var pvcDeps []*DependencyData
+	for _, ref := range reference.PodReferences(pod) {
+		key := ref.Kind + "/" + ref.Name
+		dep, ok := deps[key]
+		if !ok {
+			dep = &DependencyData{Name: ref.Name, Optional: true}
+			deps[key] = dep
+			switch ref.Kind {
+			case reference.KindConfigMap:
+				data.ConfigMaps = append(data.ConfigMaps, dep)
+			case reference.KindSecret:
+				data.Secrets = append(data.Secrets, dep)
+			case reference.KindPersistentVolumeClaim:
+				pvcDeps = append(pvcDeps, dep)
+			case reference.KindServiceAccount:
+				data.ServiceAccount = dep
 			}
-			continue
 		}
-		if volume.PersistentVolumeClaim != nil {
-			data[volume.Name] = &PodVolumeData{
-				ResourceName: volume.PersistentVolumeClaim.ClaimName,
-				ReadOnly:     &volume.PersistentVolumeClaim.ReadOnly,
-				VolumeType:   comm.VolumePersistentVolumeClaim,
+		dep.References = append(dep.References, ref)
+		dep.Optional = dep.Optional && ref.Optional
+	}
+
+	for _, dep := range data.ConfigMaps {
+		obj, found := p.getByKey(p.Dependency.ConfigMap, pod.Namespace, dep.Name)
+		if found {
+			cm := obj.(*v1.ConfigMap)
+			keys := make(map[string]struct{}, len(cm.Data)+len(cm.BinaryData))
+			for k := range cm.Data {
+				keys[k] = struct{}{}
+			}
+			for k := range cm.BinaryData {
+				keys[k] = struct{}{}
+			}
+			dep.MissingKeys = missingKeys(dep.References, keys)
+		}
+		dep.setFound(found)
+	}
+	for _, dep := range data.Secrets {
+		obj, found := p.getByKey(p.Dependency.Secret, pod.Namespace, dep.Name)
+		if found {
+			secret := obj.(*v1.Secret)
+			keys := make(map[string]struct{}, len(secret.Data))
+			for k := range secret.Data {
+				keys[k] = struct{}{}
+			}
+			dep.MissingKeys = missingKeys(dep.References, keys)
+		}
+		dep.setFound(found)
+	}
+	if data.ServiceAccount != nil {
+		_, found := p.getByKey(p.Dependency.ServiceAccount, pod.Namespace, data.ServiceAccount.Name)
+		data.ServiceAccount.setFound(found)
+	}
+	for _, dep := range pvcDeps {
+		data.PersistentVolumeClaims = append(data.PersistentVolumeClaims, p.pvcDependency(pod.Namespace, dep))
+	}
+
+	for _, dep := range data.ConfigMaps {
+		if dep.Missing {
+			data.Missing = append(data.Missing, reference.KindConfigMap+"/"+dep.Name)
+		}
+	}
+	for _, dep := range data.Secrets {
+		if dep.Missing {
+			data.Missing = append(data.Missing, reference.KindSecret+"/"+dep.Name)
+		}
+	}
+	for _, dep := range data.PersistentVolumeClaims {
+		if dep.Missing {
+			data.Missing = append(data.Missing, reference.KindPersistentVolumeClaim+"/"+dep.Name)
+		}
+	}
+	if data.ServiceAccount != nil && data.ServiceAccount.Missing {
+		data.Missing = append(data.Missing, reference.KindServiceAccount+"/"+data.ServiceAccount.Name)
+	}
+	return data
+}
+
+func (p *PodLogic) pvcDependency(namespace string, dep *DependencyData) *PVCDependencyData {
+	data := &PVCDependencyData{DependencyData: dep}
+	obj, found := p.getByKey(p.Dependency.PersistentVolumeClaim, namespace, dep.Name)
+	dep.setFound(found)
+	if !found {
+		return data
+	}
+
+	pvc := obj.(*v1.PersistentVolumeClaim)
+	data.Phase = string(pvc.Status.Phase)
+	data.VolumeName = pvc.Spec.VolumeName
+	if storage, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		data.Capacity = storage.String()
+	}
+	for _, mode := range pvc.Status.AccessModes {
+		data.AccessModes = append(data.AccessModes, string(mode))
+	}
+	if pvc.Spec.StorageClassName != nil {
+		data.StorageClass = *pvc.Spec.StorageClassName
+	}
+
+	if len(data.VolumeName) != 0 {
+		if obj, found := p.getByKey(p.Dependency.PersistentVolume, "", data.VolumeName); found {
+			pv := obj.(*v1.PersistentVolume)
+			data.VolumeFound = true
+			data.VolumePhase = string(pv.Status.Phase)
+			data.ReclaimPolicy = string(pv.Spec.PersistentVolumeReclaimPolicy)
+			if len(data.StorageClass) == 0 {
+				data.StorageClass = pv.Spec.StorageClassName
 			}
+		}
+	}
+	if len(data.StorageClass) != 0 {
+		if obj, found := p.getByKey(p.Dependency.StorageClass, "", data.StorageClass); found {
+			data.StorageClassFound = true
+			data.Provisioner = obj.(*storagev1.StorageClass).Provisioner
+		}
+	}
+	return data
+}
+
+func (p *PodLogic) getByKey(informer cache.SharedIndexInformer, namespace, name string) (any, bool) {
+	key := name
+	if len(namespace) != 0 {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := informer.GetStore().GetByKey(key)
+	if err != nil {
+		p.Log.Error(err, "get object by key", "key", key)
+		return nil, false
+	}
+	return obj, exists
+}
+
+func (d *DependencyData) setFound(found bool) {
+	d.Found = found
+	d.Missing = !found && !d.Optional
+}
+
+// missingKeys env中引用的key在ConfigMap/Secret中不存在，optional的引用不计入
+func missingKeys(refs []*reference.Reference, keys map[string]struct{}) []string {
+	var data []string
+	for _, ref := range refs {
+		if len(ref.Key) == 0 || ref.Optional {
 			continue
 		}
+		if _, ok := keys[ref.Key]; !ok {
+			data = append(data, ref.Key)
+		}
+	}
+	return data
+}
+
+// podMetrics metrics-server不可用时返回nil，只是不展示实际用量
+func (p *PodLogic) podMetrics(ctx context.Context, namespace string) map[string]v1.ResourceList {
+	data, err := p.Metrics.PodMetrics(ctx, namespace)
+	if err != nil {
+		if !errors.Is(err, metrics.ErrMetricsUnavailable) {
+			p.Log.Error(err, "get pod metrics err")
+		}
+		return nil
 	}
 	return data
 }
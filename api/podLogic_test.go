@@ -0,0 +1,59 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/k8s/metrics"
+)
+
+func TestPodListByNsUsage(t *testing.T) {
+	provider := &metrics.FakeProvider{Pods: map[string]map[string]v1.ResourceList{
+		"default": {
+			"web": {
+				v1.ResourceCPU:    resource.MustParse("250m"),
+				v1.ResourceMemory: resource.MustParse("512Mi"),
+			},
+		},
+	}}
+	nodeInformer := newTestInformer(t, &v1.Node{}, cache.Indexers{}, testNode("node-1"))
+	podInformer := newTestInformer(t, &v1.Pod{}, testPodIndexers,
+		testPod("default", "web", "node-1"), testPod("default", "worker", "node-1"), testPod("other", "web", "node-1"))
+	p := NewPodLogic(logr.Discard(), nil, provider, nodeInformer, podInformer, nil)
+
+	var data []*PodData
+	serveTest(t, p.PodListByNs, "/pods/:ns", "/pods/default", &data)
+
+	if len(data) != 2 {
+		t.Fatalf("got %d pods, want 2", len(data))
+	}
+	for _, pod := range data {
+		switch pod.Name {
+		case "web":
+			if pod.CpuUsage != "250m" || pod.MemoryUsage != "512Mi" {
+				t.Errorf("web usage = %s/%s, want 250m/512Mi", pod.CpuUsage, pod.MemoryUsage)
+			}
+		case "worker":
+			if pod.CpuUsage != "" || pod.MemoryUsage != "" {
+				t.Errorf("worker usage = %s/%s, want empty without metrics", pod.CpuUsage, pod.MemoryUsage)
+			}
+		}
+	}
+}
+
+func TestPodListByNsMetricsUnavailable(t *testing.T) {
+	nodeInformer := newTestInformer(t, &v1.Node{}, cache.Indexers{}, testNode("node-1"))
+	podInformer := newTestInformer(t, &v1.Pod{}, testPodIndexers, testPod("default", "web", "node-1"))
+	p := NewPodLogic(logr.Discard(), nil, &metrics.FakeProvider{Err: metrics.ErrMetricsUnavailable}, nodeInformer, podInformer, nil)
+
+	var data []*PodData
+	serveTest(t, p.PodListByNs, "/pods/:ns", "/pods/default", &data)
+
+	if len(data) != 1 || data[0].CpuUsage != "" {
+		t.Errorf("pods = %+v, want one pod without usage", data)
+	}
+}
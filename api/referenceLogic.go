@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/reference"
+)
+
+type ReferenceLogic struct {
+	Log           logr.Logger
+	DynamicClient dynamic.Interface
+	PodInformer   cache.SharedIndexInformer
+}
+
+type UsageData struct {
+	Kind      string          `json:"kind"`
+	Namespace string          `json:"namespace"`
+	Name      string          `json:"name"`
+	Pods      []*UsagePodData `json:"pods"`
+	Workloads []*WorkloadData `json:"workloads"`
+}
+
+type UsagePodData struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"`
+	NodeName   string                 `json:"nodeName"`
+	Workload   *WorkloadData          `json:"workload,omitempty"`
+	References []*reference.Reference `json:"references"`
+}
+
+type WorkloadData struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// usageKinds url中的类型到pod informer索引和对象Kind的映射
+var usageKinds = map[string]struct {
+	index string
+	kind  string
+}{
+	"configmap": {index: informerfactory.ConfigMapIndex, kind: reference.KindConfigMap},
+	"secret":    {index: informerfactory.SecretIndex, kind: reference.KindSecret},
+	"pvc":       {index: informerfactory.PVCIndex, kind: reference.KindPersistentVolumeClaim},
+}
+
+// ownerGVRs 需要继续向上查找控制器的owner类型
+var ownerGVRs = map[string]schema.GroupVersionResource{
+	"ReplicaSet": comm.ReplicaSetGVR,
+	"Job":        comm.JobGVR,
+}
+
+func NewReferenceLogic(log logr.Logger, dynamicClient dynamic.Interface, podInformer cache.SharedIndexInformer) *ReferenceLogic {
+	return &ReferenceLogic{
+		Log:           log.WithName("ReferenceLogic"),
+		DynamicClient: dynamicClient,
+		PodInformer:   podInformer,
+	}
+}
+
+func (r *ReferenceLogic) Usages(ctx *gin.Context) {
+	kind, ok := usageKinds[ctx.Param("kind")]
+	ns, name := ctx.Param("ns"), ctx.Param("name")
+	if !ok || len(ns) == 0 || len(name) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "request parameter error"})
+		return
+	}
+
+	objs, err := r.PodInformer.GetIndexer().ByIndex(kind.index, ns+"/"+name)
+	if err != nil {
+		r.Log.Error(err, "get pods by index", "index", kind.index)
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := &UsageData{Kind: kind.kind, Namespace: ns, Name: name, Pods: []*UsagePodData{}, Workloads: []*WorkloadData{}}
+	workloads := make(map[WorkloadData]struct{})
+	owners := make(map[string]*WorkloadData)
+	for _, obj := range objs {
+		pod := obj.(*v1.Pod)
+		row := &UsagePodData{
+			Name:     pod.Name,
+			Status:   string(pod.Status.Phase),
+			NodeName: pod.Spec.NodeName,
+			Workload: r.podWorkload(ctx, pod, owners),
+		}
+		for _, ref := range reference.PodReferences(pod) {
+			if ref.Kind == kind.kind && ref.Name == name {
+				row.References = append(row.References, ref)
+			}
+		}
+		data.Pods = append(data.Pods, row)
+
+		if row.Workload != nil {
+			if _, ok := workloads[*row.Workload]; !ok {
+				workloads[*row.Workload] = struct{}{}
+				data.Workloads = append(data.Workloads, row.Workload)
+			}
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// podWorkload 沿着controller ownerReference查找pod所属的顶层工作负载，如 Pod -> ReplicaSet -> Deployment
+func (r *ReferenceLogic) podWorkload(ctx context.Context, pod *v1.Pod, owners map[string]*WorkloadData) *WorkloadData {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+	gvr, ok := ownerGVRs[owner.Kind]
+	if !ok {
+		return &WorkloadData{Kind: owner.Kind, Name: owner.Name}
+	}
+
+	key := owner.Kind + "/" + owner.Name
+	if workload, ok := owners[key]; ok {
+		return workload
+	}
+	workload := &WorkloadData{Kind: owner.Kind, Name: owner.Name}
+	obj, err := r.DynamicClient.Resource(gvr).Namespace(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "get owner err", "kind", owner.Kind, "name", owner.Name)
+		}
+	} else if parent := metav1.GetControllerOf(obj); parent != nil {
+		workload = &WorkloadData{Kind: parent.Kind, Name: parent.Name}
+	}
+	owners[key] = workload
+	return workload
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+
+	"easy-k8s/pkg/usage"
+)
+
+type ReportLogic struct {
+	Log      logr.Logger
+	Reporter *usage.Reporter
+}
+
+type UsageReportReq struct {
+	From    string `json:"from" form:"from"`
+	To      string `json:"to" form:"to"`
+	Period  string `json:"period" form:"period"`
+	GroupBy string `json:"groupBy" form:"groupBy"`
+	Format  string `json:"format" form:"format"`
+}
+
+func NewReportLogic(log logr.Logger, reporter *usage.Reporter) *ReportLogic {
+	return &ReportLogic{
+		Log:      log.WithName("ReportLogic"),
+		Reporter: reporter,
+	}
+}
+
+func (r *ReportLogic) UsageReport(ctx *gin.Context) {
+	req := UsageReportReq{Period: usage.PeriodDaily, GroupBy: usage.GroupByNamespace, Format: "json"}
+	if err := ctx.BindQuery(&req); err != nil {
+		r.Log.Error(err, "bind query err")
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+
+	// 默认统计最近7天，日期均按本地时区解析，to当天包含在内
+	to := time.Now()
+	from := to.AddDate(0, 0, -7)
+	var err error
+	if len(req.From) != 0 {
+		if from, err = time.ParseInLocation(time.DateOnly, req.From, time.Local); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+			return
+		}
+	}
+	if len(req.To) != 0 {
+		if to, err = time.ParseInLocation(time.DateOnly, req.To, time.Local); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	report, err := r.Reporter.Usage(from, to, req.Period, req.GroupBy)
+	if err != nil {
+		r.Log.Error(err, "usage report err")
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+
+	if req.Format == "csv" {
+		ctx.Header("Content-Type", "text/csv")
+		ctx.Header("Content-Disposition", "attachment; filename=usage-"+req.Period+".csv")
+		if err = r.Reporter.WriteCSV(ctx.Writer, report); err != nil {
+			r.Log.Error(err, "write csv err")
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": report})
+}
@@ -0,0 +1,388 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/k8s/informerfactory"
+)
+
+// ResourceLogic 基于discovery和DynamicClient的通用资源浏览，支持内置资源和CRD
+type ResourceLogic struct {
+	Log           logr.Logger
+	DynamicClient dynamic.Interface
+	Discovery     discovery.CachedDiscoveryInterface
+	Factory       *informerfactory.InformerFactory
+	Authorizer    *Authorizer
+
+	invalidateLock sync.Mutex
+	invalidatedAt  time.Time
+}
+
+type APIResourceData struct {
+	Group      string   `json:"group"`
+	Version    string   `json:"version"`
+	Resource   string   `json:"resource"`
+	Kind       string   `json:"kind"`
+	Namespaced bool     `json:"namespaced"`
+	Verbs      []string `json:"verbs"`
+	ShortNames []string `json:"shortNames,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+type ResourceListReq struct {
+	LabelSelector string `json:"labelSelector" form:"labelSelector"`
+	FieldSelector string `json:"fieldSelector" form:"fieldSelector"`
+	Limit         int64  `json:"limit" form:"limit"`
+	Continue      string `json:"continue" form:"continue"`
+	// Cached 从按需创建的dynamic informer缓存中读取，首次请求时informer尚未同步返回503
+	Cached bool `json:"cached" form:"cached"`
+}
+
+// url中使用core表示核心API组
+const coreGroup = "core"
+
+const tableAcceptHeader = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
+// 资源找不到时最多每隔该时间刷新一次discovery缓存，避免请求不存在的资源时反复全量discovery
+const discoveryInvalidateInterval = 30 * time.Second
+
+// 未授权时Secret的值替换为该占位符，占位符不是合法的base64，误将结果重新apply时会被拒绝
+const secretRedacted = "<redacted>"
+
+func NewResourceLogic(log logr.Logger, dynamicClient dynamic.Interface, discoveryClient discovery.CachedDiscoveryInterface, factory *informerfactory.InformerFactory, authorizer *Authorizer) *ResourceLogic {
+	return &ResourceLogic{
+		Log:           log.WithName("ResourceLogic"),
+		DynamicClient: dynamicClient,
+		Discovery:     discoveryClient,
+		Factory:       factory,
+		Authorizer:    authorizer,
+	}
+}
+
+// InformerStatus 返回所有informer的启动和同步状态
+func (r *ResourceLogic) InformerStatus(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"data": r.Factory.SyncStatus()})
+}
+
+// APIResources 返回集群中所有API组的资源，refresh=true时刷新discovery缓存
+func (r *ResourceLogic) APIResources(ctx *gin.Context) {
+	if ctx.Query("refresh") == "true" {
+		r.Discovery.Invalidate()
+	}
+	_, lists, err := r.Discovery.ServerGroupsAndResources()
+	var failedGroups []string
+	if err != nil {
+		// 部分聚合API不可用时仍返回其他API组的资源
+		groupErr, ok := err.(*discovery.ErrGroupDiscoveryFailed)
+		if !ok {
+			r.Log.Error(err, "discovery err")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+		for gv := range groupErr.Groups {
+			failedGroups = append(failedGroups, gv.String())
+		}
+		sort.Strings(failedGroups)
+	}
+
+	var data []*APIResourceData
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, item := range list.APIResources {
+			// 跳过 pods/log 这类子资源
+			if strings.Contains(item.Name, "/") {
+				continue
+			}
+			data = append(data, &APIResourceData{
+				Group:      gv.Group,
+				Version:    gv.Version,
+				Resource:   item.Name,
+				Kind:       item.Kind,
+				Namespaced: item.Namespaced,
+				Verbs:      item.Verbs,
+				ShortNames: item.ShortNames,
+				Categories: item.Categories,
+			})
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": data, "failedGroups": failedGroups})
+}
+
+// ResourceList GET /resources/:group/:version/:resource 列出所有namespace或集群级别的对象
+func (r *ResourceLogic) ResourceList(ctx *gin.Context) {
+	r.listOrGet(ctx, "", "")
+}
+
+// ResourceListByNs GET /resources/:group/:version/:resource/:ns 对namespace级别的资源列出该namespace下的对象，
+// 对集群级别的资源 :ns 即为对象名称
+func (r *ResourceLogic) ResourceListByNs(ctx *gin.Context) {
+	gvr, apiResource, ok := r.resolve(ctx)
+	if !ok {
+		return
+	}
+	if apiResource.Namespaced {
+		r.list(ctx, gvr, ctx.Param("ns"))
+		return
+	}
+	r.get(ctx, gvr, "", ctx.Param("ns"))
+}
+
+// ResourceDetail GET /resources/:group/:version/:resource/:ns/:name
+func (r *ResourceLogic) ResourceDetail(ctx *gin.Context) {
+	r.listOrGet(ctx, ctx.Param("ns"), ctx.Param("name"))
+}
+
+func (r *ResourceLogic) listOrGet(ctx *gin.Context, ns, name string) {
+	gvr, apiResource, ok := r.resolve(ctx)
+	if !ok {
+		return
+	}
+	if !apiResource.Namespaced {
+		ns = ""
+	}
+	if len(name) == 0 {
+		r.list(ctx, gvr, ns)
+		return
+	}
+	r.get(ctx, gvr, ns, name)
+}
+
+func (r *ResourceLogic) list(ctx *gin.Context, gvr schema.GroupVersionResource, ns string) {
+	var req ResourceListReq
+	if err := ctx.BindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	if req.Cached {
+		r.listFromCache(ctx, gvr, ns, req.LabelSelector)
+		return
+	}
+	options := metav1.ListOptions{LabelSelector: req.LabelSelector, FieldSelector: req.FieldSelector, Limit: req.Limit, Continue: req.Continue}
+
+	redact := r.redact(ctx, gvr)
+	if wantTable(ctx) {
+		request := r.Discovery.RESTClient().Get().AbsPath(resourcePath(gvr, ns, "")).
+			SetHeader("Accept", tableAcceptHeader).
+			VersionedParams(&options, metav1.ParameterCodec)
+		if redact {
+			// 行中的metadata包含last-applied-configuration注解，其中有Secret的值
+			request.Param("includeObject", string(metav1.IncludeNone))
+		}
+		r.writeRaw(ctx, request.Do(ctx).Raw)
+		return
+	}
+
+	list, err := r.DynamicClient.Resource(gvr).Namespace(ns).List(ctx, options)
+	if err != nil {
+		r.Log.Error(err, "list resource err", "gvr", gvr.String())
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	if redact {
+		for i := range list.Items {
+			redactSecret(&list.Items[i])
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": list.UnstructuredContent()})
+}
+
+func (r *ResourceLogic) listFromCache(ctx *gin.Context, gvr schema.GroupVersionResource, ns, labelSelector string) {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	informer := r.Factory.ForResource(gvr)
+	if !informer.HasSynced() {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"msg": "informer of " + gvr.String() + " is syncing, retry later"})
+		return
+	}
+
+	var objs []any
+	if len(ns) == 0 {
+		objs = informer.GetStore().List()
+	} else {
+		objs, err = informer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+		if err != nil {
+			r.Log.Error(err, "list from cache err", "gvr", gvr.String())
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+	}
+
+	var matched []*unstructured.Unstructured
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if ok && selector.Matches(labels.Set(u.GetLabels())) {
+			matched = append(matched, u)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if matched[i].GetNamespace() != matched[j].GetNamespace() {
+			return matched[i].GetNamespace() < matched[j].GetNamespace()
+		}
+		return matched[i].GetName() < matched[j].GetName()
+	})
+	redact := r.redact(ctx, gvr)
+	items := make([]map[string]any, 0, len(matched))
+	for _, u := range matched {
+		if redact {
+			// 缓存中的对象不能修改
+			u = u.DeepCopy()
+			redactSecret(u)
+		}
+		items = append(items, u.UnstructuredContent())
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+func (r *ResourceLogic) get(ctx *gin.Context, gvr schema.GroupVersionResource, ns, name string) {
+	redact := r.redact(ctx, gvr)
+	if wantTable(ctx) {
+		request := r.Discovery.RESTClient().Get().AbsPath(resourcePath(gvr, ns, name)).SetHeader("Accept", tableAcceptHeader)
+		if redact {
+			request.Param("includeObject", string(metav1.IncludeNone))
+		}
+		r.writeRaw(ctx, request.Do(ctx).Raw)
+		return
+	}
+
+	obj, err := r.DynamicClient.Resource(gvr).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.Log.Error(err, "get resource err", "gvr", gvr.String(), "namespace", ns, "name", name)
+		}
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	if redact {
+		redactSecret(obj)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": obj.UnstructuredContent()})
+}
+
+// redact Secret的值只返回给已授权的请求
+func (r *ResourceLogic) redact(ctx *gin.Context, gvr schema.GroupVersionResource) bool {
+	return isSecret(gvr) && !r.Authorizer.Authorized(ctx)
+}
+
+func (r *ResourceLogic) writeRaw(ctx *gin.Context, raw func() ([]byte, error)) {
+	body, err := raw()
+	if err != nil {
+		r.Log.Error(err, "request table err")
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": json.RawMessage(body)})
+}
+
+// resolve 通过discovery确认资源存在并获取是否为namespace级别，找不到时刷新一次缓存以发现新安装的CRD
+func (r *ResourceLogic) resolve(ctx *gin.Context) (schema.GroupVersionResource, *metav1.APIResource, bool) {
+	gvr := schema.GroupVersionResource{Group: ctx.Param("group"), Version: ctx.Param("version"), Resource: ctx.Param("resource")}
+	if gvr.Group == coreGroup {
+		gvr.Group = ""
+	}
+
+	apiResource, err := findAPIResource(r.Discovery, gvr)
+	if (err != nil || apiResource == nil) && r.invalidateDiscovery() {
+		apiResource, err = findAPIResource(r.Discovery, gvr)
+	}
+	if err != nil && !apierrors.IsNotFound(err) {
+		r.Log.Error(err, "discovery err", "gvr", gvr.String())
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return gvr, nil, false
+	}
+	if apiResource == nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": "resource " + gvr.String() + " not found"})
+		return gvr, nil, false
+	}
+	return gvr, apiResource, true
+}
+
+// invalidateDiscovery 距上次刷新超过discoveryInvalidateInterval时才刷新discovery缓存，返回是否刷新
+func (r *ResourceLogic) invalidateDiscovery() bool {
+	r.invalidateLock.Lock()
+	defer r.invalidateLock.Unlock()
+	if time.Since(r.invalidatedAt) < discoveryInvalidateInterval {
+		return false
+	}
+	r.invalidatedAt = time.Now()
+	r.Discovery.Invalidate()
+	return true
+}
+
+func findAPIResource(discoveryClient discovery.DiscoveryInterface, gvr schema.GroupVersionResource) (*metav1.APIResource, error) {
+	list, err := discoveryClient.ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.APIResources {
+		if list.APIResources[i].Name == gvr.Resource {
+			return &list.APIResources[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// resourcePath 拼接资源的REST路径，核心组为 /api/v1，其他组为 /apis/<group>/<version>
+func resourcePath(gvr schema.GroupVersionResource, ns, name string) string {
+	parts := []string{"/apis", gvr.Group, gvr.Version}
+	if len(gvr.Group) == 0 {
+		parts = []string{"/api", gvr.Version}
+	}
+	if len(ns) != 0 {
+		parts = append(parts, "namespaces", ns)
+	}
+	parts = append(parts, gvr.Resource)
+	if len(name) != 0 {
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, "/")
+}
+
+func wantTable(ctx *gin.Context) bool {
+	return strings.Contains(ctx.GetHeader("Accept"), "as=Table")
+}
+
+func isSecret(gvr schema.GroupVersionResource) bool {
+	return gvr.Group == "" && gvr.Resource == "secrets"
+}
+
+// redactSecret 将Secret的data和stringData的值替换为占位符，保留key；
+// kubectl apply写入的last-applied-configuration注解中也包含原始值，一并删除
+func redactSecret(obj *unstructured.Unstructured) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj.Object[field].(map[string]any)
+		if !ok {
+			continue
+		}
+		for key := range values {
+			values[key] = secretRedacted
+		}
+	}
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[v1.LastAppliedConfigAnnotation]; ok {
+		delete(annotations, v1.LastAppliedConfigAnnotation)
+		obj.SetAnnotations(annotations)
+	}
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestRedactSecret(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]any{
+			"name": "db",
+			"annotations": map[string]any{
+				v1.LastAppliedConfigAnnotation: `{"data":{"password":"c2VjcmV0"}}`,
+				"team":                         "infra",
+			},
+		},
+		"data":       map[string]any{"password": "c2VjcmV0"},
+		"stringData": map[string]any{"user": "admin"},
+	}}
+
+	redactSecret(obj)
+
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	stringData, _, _ := unstructured.NestedStringMap(obj.Object, "stringData")
+	if data["password"] != secretRedacted || stringData["user"] != secretRedacted {
+		t.Errorf("data = %v, stringData = %v, want values redacted", data, stringData)
+	}
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[v1.LastAppliedConfigAnnotation]; ok {
+		t.Error("last-applied-configuration annotation not removed")
+	}
+	if annotations["team"] != "infra" {
+		t.Errorf("annotations = %v, want other annotations kept", annotations)
+	}
+}
@@ -2,20 +2,60 @@ package api
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/cache"
 
+	"easy-k8s/pkg/alert"
+	"easy-k8s/pkg/events"
+	"easy-k8s/pkg/k8s/apply"
 	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/metrics"
+	"easy-k8s/pkg/nodehistory"
+	"easy-k8s/pkg/nstemplate"
+	"easy-k8s/pkg/prometheus"
+	"easy-k8s/pkg/store"
+	"easy-k8s/pkg/usage"
 )
 
 type ApiServer struct {
-	Log           logr.Logger
-	DynamicClient dynamic.Interface
-	nodeInformer  cache.SharedIndexInformer
-	podInformer   cache.SharedIndexInformer
+	Log                   logr.Logger
+	AdminToken            string
+	DynamicClient         dynamic.Interface
+	Discovery             discovery.CachedDiscoveryInterface
+	MetadataClient        metadata.Interface
+	Applier               *apply.Applier
+	Metrics               metrics.Provider
+	Store                 *store.Store
+	UsageConfig           *usage.Config
+	PrometheusConfig      *prometheus.Config
+	NamespaceTemplate     *nstemplate.Template
+	EventRetention        time.Duration
+	EventCapacity         int
+	Alerter               *alert.Alerter
+	NodeHistoryRetention  time.Duration
+	nodeInformer          cache.SharedIndexInformer
+	podInformer           cache.SharedIndexInformer
+	namespaceInformer     cache.SharedIndexInformer
+	podDependency         *PodDependencyInformers
+	serviceInformer       cache.SharedIndexInformer
+	endpointSliceInformer cache.SharedIndexInformer
+	ingressInformer       cache.SharedIndexInformer
+	networkPolicyInformer cache.SharedIndexInformer
+	resourceQuotaInformer cache.SharedIndexInformer
+	limitRangeInformer    cache.SharedIndexInformer
+	eventInformer         cache.SharedIndexInformer
+	graphChildInformers   map[schema.GroupVersionResource]cache.SharedIndexInformer
+	eventArchive          *events.Archive
+	nodeHistory           *nodehistory.Recorder
+	factory               *informerfactory.InformerFactory
 }
 
 func (s *ApiServer) Engine() *gin.Engine {
@@ -24,7 +64,7 @@ func (s *ApiServer) Engine() *gin.Engine {
 		c.JSON(200, gin.H{"message": "has been successfully run"})
 	})
 
-	node := NewNodeLogic(s.Log, s.DynamicClient, s.nodeInformer, s.podInformer)
+	node := NewNodeLogic(s.Log, s.DynamicClient, s.Metrics, s.nodeInformer, s.podInformer)
 	engine.GET("/getConf", node.GetDisplayFileds)
 	engine.POST("/setConf", node.SetDisplayFileds)
 	engine.GET("/nodeList", node.GetNodeList)
@@ -32,17 +72,131 @@ func (s *ApiServer) Engine() *gin.Engine {
 	engine.POST("/nodeLabels/:node", node.NodeLabelPatch)
 	engine.GET("/nodeResource/:node", node.NodeResource)
 	engine.GET("/nodePodList/:node", node.NodePodList)
+	engine.GET("/nodeConditions/:node", node.NodeConditions)
+	engine.GET("/nodes/unhealthy", node.UnhealthyNodes)
 
-	pod := NewPodLogic(s.Log, s.DynamicClient, s.nodeInformer, s.podInformer)
+	nodeHistory := NewNodeHistoryLogic(s.Log, s.nodeHistory)
+	engine.GET("/nodeHistory/:node", nodeHistory.NodeHistory)
+
+	nodeMetrics := NewMetricsLogic(s.Log, s.PrometheusConfig, s.nodeInformer)
+	engine.GET("/nodeMetrics/:node", nodeMetrics.NodeMetricsHistory)
+
+	pod := NewPodLogic(s.Log, s.DynamicClient, s.Metrics, s.nodeInformer, s.podInformer, s.podDependency)
 	engine.GET("/podListByNs/:ns", pod.PodListByNs)
 	engine.GET("/podAssociatedResources/:ns/:name", pod.PodAssociatedResources)
+
+	ref := NewReferenceLogic(s.Log, s.DynamicClient, s.podInformer)
+	engine.GET("/usages/:kind/:ns/:name", ref.Usages)
+
+	authorizer := NewAuthorizer(s.AdminToken)
+	config := NewConfigLogic(s.Log, s.DynamicClient, authorizer)
+	engine.GET("/configMapList/:ns", config.ConfigMapList)
+	engine.GET("/configMap/:ns/:name", config.ConfigMapDetail)
+	engine.PUT("/configMap/:ns/:name", config.ConfigMapUpdate)
+	engine.GET("/secretList/:ns", config.SecretList)
+	engine.GET("/secret/:ns/:name", config.SecretDetail)
+	engine.PUT("/secret/:ns/:name", config.SecretUpdate)
+
+	storage := NewStorageLogic(s.Log, s.DynamicClient, s.podInformer, s.podDependency.PersistentVolumeClaim, s.podDependency.PersistentVolume, s.podDependency.StorageClass)
+	engine.GET("/pvcList/:ns", storage.PVCList)
+	engine.GET("/pvList", storage.PVList)
+	engine.GET("/storageClassList", storage.StorageClassList)
+	engine.GET("/stuckPvcList", storage.StuckPVCList)
+
+	network := NewNetworkLogic(s.Log, s.podInformer, s.namespaceInformer, s.serviceInformer, s.endpointSliceInformer, s.ingressInformer, s.networkPolicyInformer)
+	engine.GET("/serviceList/:ns", network.ServiceList)
+	engine.GET("/ingressList/:ns", network.IngressList)
+	engine.GET("/podNetwork/:ns/:name", network.PodNetwork)
+
+	namespace := NewNamespaceLogic(s.Log, s.DynamicClient, authorizer, s.NamespaceTemplate, s.namespaceInformer, s.podInformer, s.resourceQuotaInformer, s.limitRangeInformer)
+	engine.GET("/namespaces", namespace.NamespaceList)
+	engine.GET("/namespaces/:ns", namespace.NamespaceDetail)
+	engine.POST("/namespaces", namespace.NamespaceCreate)
+	engine.DELETE("/namespaces/:ns", namespace.NamespaceDelete)
+
+	resource := NewResourceLogic(s.Log, s.DynamicClient, s.Discovery, s.factory, authorizer)
+	engine.GET("/apiResources", resource.APIResources)
+	engine.GET("/resources/:group/:version/:resource", resource.ResourceList)
+	engine.GET("/resources/:group/:version/:resource/:ns", resource.ResourceListByNs)
+	engine.GET("/resources/:group/:version/:resource/:ns/:name", resource.ResourceDetail)
+	engine.GET("/informers", resource.InformerStatus)
+
+	yamlLogic := NewYamlLogic(s.Log, s.Applier, authorizer)
+	engine.GET("/yaml/:group/:version/:resource/:ns", yamlLogic.YamlByNs)
+	engine.GET("/yaml/:group/:version/:resource/:ns/:name", yamlLogic.YamlDetail)
+	engine.POST("/yaml", yamlLogic.YamlApply)
+
+	manifest := NewManifestLogic(s.Log, s.Applier, authorizer)
+	engine.POST("/manifests", manifest.ManifestApply)
+
+	deleteLogic := NewDeleteLogic(s.Log, s.Applier, s.Discovery, s.MetadataClient, authorizer)
+	engine.DELETE("/resources/:group/:version/:resource/:ns", deleteLogic.DeleteByNs)
+	engine.DELETE("/resources/:group/:version/:resource/:ns/:name", deleteLogic.DeleteDetail)
+	engine.GET("/terminating/:group/:version/:resource/:ns", deleteLogic.TerminatingByNs)
+	engine.GET("/terminating/:group/:version/:resource/:ns/:name", deleteLogic.TerminatingDetail)
+	engine.DELETE("/finalizers/:group/:version/:resource/:ns", deleteLogic.RemoveFinalizersByNs)
+	engine.DELETE("/finalizers/:group/:version/:resource/:ns/:name", deleteLogic.RemoveFinalizersDetail)
+
+	graph := NewGraphLogic(s.Log, s.Applier, s.Discovery, s.MetadataClient, s.graphChildInformers, s.podInformer, s.serviceInformer, s.podDependency)
+	engine.GET("/graph/:kind/:ns/:name", graph.Graph)
+
+	event := NewEventLogic(s.Log, s.eventInformer, s.eventArchive)
+	engine.GET("/events", event.EventList)
+	engine.GET("/events/stream", event.EventStream)
+
+	report := NewReportLogic(s.Log, usage.NewReporter(s.UsageConfig, s.Store))
+	engine.GET("/reports/usage", report.UsageReport)
 	return engine
 }
 
-func (s *ApiServer) RunInformerFactory(factory *informerfactory.InformerFactory, ctx context.Context) {
+// RunInformerFactory 启动informer和后台任务，返回的WaitGroup在ctx取消且后台任务退出后完成，关闭store前需要等待
+func (s *ApiServer) RunInformerFactory(factory *informerfactory.InformerFactory, ctx context.Context) *sync.WaitGroup {
+	s.factory = factory
 	s.nodeInformer = factory.Node()
 	s.podInformer = factory.Pod()
+	s.namespaceInformer = factory.Namespace()
+	s.podDependency = &PodDependencyInformers{
+		ConfigMap:             factory.ConfigMap(),
+		Secret:                factory.Secret(),
+		PersistentVolumeClaim: factory.PersistentVolumeClaim(),
+		PersistentVolume:      factory.PersistentVolume(),
+		StorageClass:          factory.StorageClass(),
+		ServiceAccount:        factory.ServiceAccount(),
+	}
+	s.serviceInformer = factory.Service()
+	s.endpointSliceInformer = factory.EndpointSlice()
+	s.ingressInformer = factory.Ingress()
+	s.networkPolicyInformer = factory.NetworkPolicy()
+	s.resourceQuotaInformer = factory.ResourceQuota()
+	s.limitRangeInformer = factory.LimitRange()
+	s.eventInformer = factory.Event()
+	s.graphChildInformers = make(map[schema.GroupVersionResource]cache.SharedIndexInformer, len(graphChildKinds))
+	for gvr := range graphChildKinds {
+		s.graphChildInformers[gvr] = factory.ForResourceMetadata(gvr)
+	}
+	s.eventArchive = events.NewArchive(s.Log, s.Store, s.EventRetention, s.EventCapacity)
+	s.eventInformer.AddEventHandler(s.eventArchive.Handler())
+	s.nodeHistory = nodehistory.NewRecorder(s.Log, s.Store, s.NodeHistoryRetention)
+	s.nodeInformer.AddEventHandler(s.nodeHistory.Handler())
+	if s.Alerter.Enabled() {
+		s.Alerter.Register(s.nodeInformer, s.podInformer, s.eventInformer)
+	}
 
 	factory.Start(ctx.Done())
 	factory.WaitForCacheSync(ctx.Done())
+
+	sampler := usage.NewSampler(s.Log, s.UsageConfig, s.Store, s.nodeInformer, s.podInformer, s.namespaceInformer)
+	runs := []func(context.Context){sampler.Run, s.eventArchive.Run, s.nodeHistory.Run}
+	if s.Alerter.Enabled() {
+		runs = append(runs, s.Alerter.Run)
+	}
+	wg := &sync.WaitGroup{}
+	for _, run := range runs {
+		wg.Add(1)
+		go func(run func(context.Context)) {
+			defer wg.Done()
+			run(ctx)
+		}(run)
+	}
+	return wg
 }
@@ -0,0 +1,302 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/informerfactory"
+)
+
+type StorageLogic struct {
+	Log                  logr.Logger
+	DynamicClient        dynamic.Interface
+	PodInformer          cache.SharedIndexInformer
+	PVCInformer          cache.SharedIndexInformer
+	PVInformer           cache.SharedIndexInformer
+	StorageClassInformer cache.SharedIndexInformer
+}
+
+type PVCListData struct {
+	Name         string   `json:"name"`
+	Namespace    string   `json:"namespace"`
+	Phase        string   `json:"phase"`
+	Capacity     string   `json:"capacity,omitempty"`
+	Request      string   `json:"request,omitempty"`
+	AccessModes  []string `json:"accessModes"`
+	StorageClass string   `json:"storageClass,omitempty"`
+	VolumeName   string   `json:"volumeName,omitempty"`
+	VolumeMode   string   `json:"volumeMode,omitempty"`
+	UsedByPods   int      `json:"usedByPods"`
+	Age          string   `json:"age"`
+}
+
+type PVListData struct {
+	Name          string   `json:"name"`
+	Phase         string   `json:"phase"`
+	Capacity      string   `json:"capacity,omitempty"`
+	AccessModes   []string `json:"accessModes"`
+	ReclaimPolicy string   `json:"reclaimPolicy"`
+	Claim         string   `json:"claim,omitempty"`
+	StorageClass  string   `json:"storageClass,omitempty"`
+	VolumeType    string   `json:"volumeType,omitempty"`
+	Driver        string   `json:"driver,omitempty"`
+	Reason        string   `json:"reason,omitempty"`
+	Age           string   `json:"age"`
+}
+
+type StorageClassListData struct {
+	Name                 string            `json:"name"`
+	Provisioner          string            `json:"provisioner"`
+	ReclaimPolicy        string            `json:"reclaimPolicy,omitempty"`
+	VolumeBindingMode    string            `json:"volumeBindingMode,omitempty"`
+	AllowVolumeExpansion bool              `json:"allowVolumeExpansion"`
+	IsDefault            bool              `json:"isDefault"`
+	Parameters           map[string]string `json:"parameters,omitempty"`
+	Age                  string            `json:"age"`
+}
+
+type StuckPVCData struct {
+	*PVCListData
+	Pending string       `json:"pending"`
+	Events  []*EventData `json:"events"`
+}
+
+type EventData struct {
+	Type          string `json:"type"`
+	Reason        string `json:"reason"`
+	Message       string `json:"message"`
+	Count         int32  `json:"count"`
+	LastTimestamp string `json:"lastTimestamp"`
+	lastTime      time.Time
+}
+
+const (
+	annDefaultStorageClass     = "storageclass.kubernetes.io/is-default-class"
+	annBetaDefaultStorageClass = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+func NewStorageLogic(log logr.Logger, dynamicClient dynamic.Interface, podInformer, pvcInformer, pvInformer, storageClassInformer cache.SharedIndexInformer) *StorageLogic {
+	return &StorageLogic{
+		Log:                  log.WithName("StorageLogic"),
+		DynamicClient:        dynamicClient,
+		PodInformer:          podInformer,
+		PVCInformer:          pvcInformer,
+		PVInformer:           pvInformer,
+		StorageClassInformer: storageClassInformer,
+	}
+}
+
+func (s *StorageLogic) PVCList(ctx *gin.Context) {
+	ns := ctx.Param("ns")
+	if ns == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "namespace is empty"})
+		return
+	}
+	objs, err := s.PVCInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns)
+	if err != nil {
+		s.Log.Error(err, "get pvc list by namespace")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+
+	data := make([]*PVCListData, 0, len(objs))
+	for _, obj := range objs {
+		data = append(data, s.pvcData(obj.(*v1.PersistentVolumeClaim)))
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (s *StorageLogic) PVList(ctx *gin.Context) {
+	var data []*PVListData
+	for _, obj := range s.PVInformer.GetStore().List() {
+		pv := obj.(*v1.PersistentVolume)
+		row := &PVListData{
+			Name:          pv.Name,
+			Phase:         string(pv.Status.Phase),
+			AccessModes:   accessModes(pv.Spec.AccessModes),
+			ReclaimPolicy: string(pv.Spec.PersistentVolumeReclaimPolicy),
+			StorageClass:  pv.Spec.StorageClassName,
+			Reason:        pv.Status.Reason,
+			Age:           translateTimestampSince(pv.CreationTimestamp),
+		}
+		if storage, ok := pv.Spec.Capacity[v1.ResourceStorage]; ok {
+			row.Capacity = storage.String()
+		}
+		if pv.Spec.ClaimRef != nil {
+			row.Claim = pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
+		}
+		switch {
+		case pv.Spec.CSI != nil:
+			row.VolumeType, row.Driver = comm.VolumeCSI, pv.Spec.CSI.Driver
+		case pv.Spec.HostPath != nil:
+			row.VolumeType = comm.VolumeHostPath
+		case pv.Spec.NFS != nil:
+			row.VolumeType = comm.VolumeNFS
+		case pv.Spec.Local != nil:
+			row.VolumeType = "local"
+		}
+		data = append(data, row)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+func (s *StorageLogic) StorageClassList(ctx *gin.Context) {
+	var data []*StorageClassListData
+	for _, obj := range s.StorageClassInformer.GetStore().List() {
+		sc := obj.(*storagev1.StorageClass)
+		row := &StorageClassListData{
+			Name:                 sc.Name,
+			Provisioner:          sc.Provisioner,
+			AllowVolumeExpansion: sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion,
+			IsDefault:            sc.Annotations[annDefaultStorageClass] == "true" || sc.Annotations[annBetaDefaultStorageClass] == "true",
+			Parameters:           sc.Parameters,
+			Age:                  translateTimestampSince(sc.CreationTimestamp),
+		}
+		if sc.ReclaimPolicy != nil {
+			row.ReclaimPolicy = string(*sc.ReclaimPolicy)
+		}
+		if sc.VolumeBindingMode != nil {
+			row.VolumeBindingMode = string(*sc.VolumeBindingMode)
+		}
+		data = append(data, row)
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Name < data[j].Name })
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// StuckPVCList 列出处于Pending状态的PVC及其相关事件，ns为空时查询所有namespace
+func (s *StorageLogic) StuckPVCList(ctx *gin.Context) {
+	ns := ctx.Query("ns")
+	var objs []any
+	if len(ns) != 0 {
+		var err error
+		if objs, err = s.PVCInformer.GetIndexer().ByIndex(cache.NamespaceIndex, ns); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+			return
+		}
+	} else {
+		objs = s.PVCInformer.GetStore().List()
+	}
+
+	var data []*StuckPVCData
+	for _, obj := range objs {
+		pvc := obj.(*v1.PersistentVolumeClaim)
+		if pvc.Status.Phase != v1.ClaimPending {
+			continue
+		}
+		data = append(data, &StuckPVCData{
+			PVCListData: s.pvcData(pvc),
+			Pending:     translateTimestampSince(pvc.CreationTimestamp),
+			Events:      []*EventData{},
+		})
+	}
+	if len(data) == 0 {
+		ctx.JSON(http.StatusOK, gin.H{"data": data})
+		return
+	}
+
+	events, err := s.pvcEvents(ctx, ns)
+	if err != nil {
+		s.Log.Error(err, "list pvc events err")
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	for _, row := range data {
+		if rowEvents, ok := events[row.Namespace+"/"+row.Name]; ok {
+			row.Events = rowEvents
+		}
+	}
+	sort.Slice(data, func(i, j int) bool {
+		if data[i].Namespace != data[j].Namespace {
+			return data[i].Namespace < data[j].Namespace
+		}
+		return data[i].Name < data[j].Name
+	})
+	ctx.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// pvcEvents 返回 namespace/name 到PVC事件的映射，按最后发生时间倒序
+func (s *StorageLogic) pvcEvents(ctx *gin.Context, ns string) (map[string][]*EventData, error) {
+	selector := fields.OneTermEqualSelector("involvedObject.kind", "PersistentVolumeClaim").String()
+	list, err := s.DynamicClient.Resource(comm.EventGVR).Namespace(ns).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]*EventData)
+	for _, item := range list.Items {
+		var event v1.Event
+		if err = runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &event); err != nil {
+			return nil, err
+		}
+		last := event.LastTimestamp.Time
+		if last.IsZero() {
+			last = event.EventTime.Time
+		}
+		row := &EventData{
+			Type:          event.Type,
+			Reason:        event.Reason,
+			Message:       event.Message,
+			Count:         event.Count,
+			LastTimestamp: translateTimestampSince(metav1.NewTime(last)),
+			lastTime:      last,
+		}
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		data[key] = append(data[key], row)
+	}
+	for _, rows := range data {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].lastTime.After(rows[j].lastTime) })
+	}
+	return data, nil
+}
+
+func (s *StorageLogic) pvcData(pvc *v1.PersistentVolumeClaim) *PVCListData {
+	row := &PVCListData{
+		Name:        pvc.Name,
+		Namespace:   pvc.Namespace,
+		Phase:       string(pvc.Status.Phase),
+		AccessModes: accessModes(pvc.Spec.AccessModes),
+		VolumeName:  pvc.Spec.VolumeName,
+		Age:         translateTimestampSince(pvc.CreationTimestamp),
+	}
+	if storage, ok := pvc.Status.Capacity[v1.ResourceStorage]; ok {
+		row.Capacity = storage.String()
+	}
+	if storage, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]; ok {
+		row.Request = storage.String()
+	}
+	if pvc.Spec.StorageClassName != nil {
+		row.StorageClass = *pvc.Spec.StorageClassName
+	}
+	if pvc.Spec.VolumeMode != nil {
+		row.VolumeMode = string(*pvc.Spec.VolumeMode)
+	}
+	pods, err := s.PodInformer.GetIndexer().ByIndex(informerfactory.PVCIndex, pvc.Namespace+"/"+pvc.Name)
+	if err != nil {
+		s.Log.Error(err, "get pods by pvc index")
+	}
+	row.UsedByPods = len(pods)
+	return row
+}
+
+func accessModes(modes []v1.PersistentVolumeAccessMode) []string {
+	data := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		data = append(data, string(mode))
+	}
+	return data
+}
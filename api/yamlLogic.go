@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"easy-k8s/pkg/comm"
+	"easy-k8s/pkg/k8s/apply"
+)
+
+// YamlLogic 任意对象的YAML查看和编辑，编辑后的YAML通过server-side apply提交
+type YamlLogic struct {
+	Log        logr.Logger
+	Applier    *apply.Applier
+	Authorizer *Authorizer
+}
+
+type YamlApplyData struct {
+	Kind         string `json:"kind"`
+	Namespace    string `json:"namespace,omitempty"`
+	Name         string `json:"name"`
+	Operation    string `json:"operation"`
+	DryRun       bool   `json:"dryRun"`
+	FieldManager string `json:"fieldManager"`
+	Yaml         string `json:"yaml"`
+}
+
+// 请求体最大为4MB，与apiserver单个对象的限制接近
+const maxYamlBodySize = 4 << 20
+
+func NewYamlLogic(log logr.Logger, applier *apply.Applier, authorizer *Authorizer) *YamlLogic {
+	return &YamlLogic{
+		Log:        log.WithName("YamlLogic"),
+		Applier:    applier,
+		Authorizer: authorizer,
+	}
+}
+
+// YamlByNs GET /yaml/:group/:version/:resource/:ns 集群级别的资源 :ns 即为对象名称
+func (y *YamlLogic) YamlByNs(ctx *gin.Context) {
+	y.yaml(ctx, "", ctx.Param("ns"))
+}
+
+// YamlDetail GET /yaml/:group/:version/:resource/:ns/:name
+func (y *YamlLogic) YamlDetail(ctx *gin.Context) {
+	y.yaml(ctx, ctx.Param("ns"), ctx.Param("name"))
+}
+
+func (y *YamlLogic) yaml(ctx *gin.Context, ns, name string) {
+	gvr := schema.GroupVersionResource{Group: ctx.Param("group"), Version: ctx.Param("version"), Resource: ctx.Param("resource")}
+	if gvr.Group == coreGroup {
+		gvr.Group = ""
+	}
+	mapping, err := y.Applier.ResourceMapping(gvr)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, gin.H{"msg": err.Error()})
+		return
+	}
+
+	obj, err := y.Applier.ResourceInterface(mapping, ns).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			y.Log.Error(err, "get resource err", "gvr", gvr.String(), "namespace", ns, "name", name)
+		}
+		ctx.JSON(apiErrorCode(err), gin.H{"msg": err.Error()})
+		return
+	}
+	obj.SetManagedFields(nil)
+	// Secret的值只返回给已授权的请求
+	if isSecret(mapping.Resource) && !y.Authorizer.Authorized(ctx) {
+		redactSecret(obj)
+	}
+
+	data, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": string(data)})
+}
+
+// YamlApply POST /yaml 请求体为单个对象的YAML，dryRun=true时只做服务端校验，force=true时强制接管冲突字段，
+// 字段冲突时返回409和冲突列表
+func (y *YamlLogic) YamlApply(ctx *gin.Context) {
+	if !y.Authorizer.Authorized(ctx) {
+		ctx.JSON(http.StatusForbidden, gin.H{"msg": comm.UnauthorizedErr.Error()})
+		return
+	}
+	body, ok := readYamlBody(ctx)
+	if !ok {
+		return
+	}
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(body, &obj.Object); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": err.Error()})
+		return
+	}
+	if len(obj.GetAPIVersion()) == 0 || len(obj.GetKind()) == 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{"msg": "apiVersion and kind are required"})
+		return
+	}
+
+	opts := apply.Options{DryRun: ctx.Query("dryRun") == "true", Force: ctx.Query("force") == "true"}
+	result, err := y.Applier.Apply(ctx, obj, opts)
+	if err != nil {
+		y.Log.Error(err, "apply err", "kind", obj.GetKind(), "namespace", obj.GetNamespace(), "name", obj.GetName())
+		code := apiErrorCode(err)
+		if meta.IsNoMatchError(err) {
+			code = http.StatusBadRequest
+		}
+		ctx.JSON(code, gin.H{"msg": err.Error(), "conflicts": apply.Conflicts(err)})
+		return
+	}
+
+	result.Object.SetManagedFields(nil)
+	data, err := yaml.Marshal(result.Object.Object)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"msg": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"data": &YamlApplyData{
+		Kind:         obj.GetKind(),
+		Namespace:    obj.GetNamespace(),
+		Name:         obj.GetName(),
+		Operation:    result.Operation,
+		DryRun:       opts.DryRun,
+		FieldManager: y.Applier.FieldManager(),
+		Yaml:         string(data),
+	}})
+}
+
+// readYamlBody 读取请求体，超过maxYamlBodySize时返回413而不是截断
+func readYamlBody(ctx *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxYamlBodySize))
+	if err != nil {
+		code := http.StatusBadRequest
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			code = http.StatusRequestEntityTooLarge
+		}
+		ctx.JSON(code, gin.H{"msg": err.Error()})
+		return nil, false
+	}
+	return body, true
+}
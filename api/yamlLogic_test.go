@@ -0,0 +1,28 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+)
+
+func TestYamlApplyBodyTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	y := NewYamlLogic(logr.Discard(), nil, NewAuthorizer("token"))
+	engine := gin.New()
+	engine.POST("/yaml", y.YamlApply)
+
+	body := bytes.Repeat([]byte("#"), maxYamlBodySize+1)
+	req := httptest.NewRequest(http.MethodPost, "/yaml", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", recorder.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -2,22 +2,44 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"k8s.io/client-go/util/homedir"
 
 	"easy-k8s/api"
+	"easy-k8s/pkg/alert"
+	"easy-k8s/pkg/k8s/apply"
 	"easy-k8s/pkg/k8s/client"
 	"easy-k8s/pkg/k8s/informerfactory"
+	"easy-k8s/pkg/k8s/metrics"
 	"easy-k8s/pkg/log"
+	"easy-k8s/pkg/nstemplate"
+	"easy-k8s/pkg/prometheus"
+	"easy-k8s/pkg/store"
+	"easy-k8s/pkg/usage"
 )
 
 var (
-	kubeconfig *string
-	logger     = log.NewStdoutLogger()
-	ctx        = context.Background()
+	kubeconfig       *string
+	dataDir          *string
+	usageConfig      *string
+	prometheusConfig *string
+	prometheusAddr   *string
+	adminToken       *string
+	namespaceTmpl    *string
+	fieldManager     *string
+	eventRetention   *time.Duration
+	eventCapacity    *int
+	alertConfig      *string
+	nodeHistoryTTL   *time.Duration
+	logger           = log.NewStdoutLogger()
 )
 
 func init() {
@@ -27,11 +49,26 @@ func init() {
 	}
 
 	kubeconfig = flag.String("kubeconfig", defaultKubeConfigPath, "absolute path to the kubeconfig file")
+	dataDir = flag.String("data-dir", "data", "directory of the local embedded database")
+	usageConfig = flag.String("usage-config", "", "path to the usage sampling and unit price config file")
+	prometheusConfig = flag.String("prometheus-config", "", "path to the prometheus address and PromQL templates config file")
+	prometheusAddr = flag.String("prometheus-addr", "", "prometheus http api address, overrides the address in prometheus-config")
+	namespaceTmpl = flag.String("namespace-template", "", "path to the namespace onboarding template file")
+	fieldManager = flag.String("field-manager", apply.DefaultFieldManager, "field manager name used by server-side apply")
+	eventRetention = flag.Duration("event-retention", 7*24*time.Hour, "how long archived events are kept in the local database")
+	eventCapacity = flag.Int("event-capacity", 100000, "max number of archived events kept in the local database")
+	alertConfig = flag.String("alert-config", "", "path to the alert rules and webhook targets config file")
+	nodeHistoryTTL = flag.Duration("node-history-retention", 30*24*time.Hour, "how long node history records are kept in the local database")
+	adminToken = flag.String("admin-token", "", "bearer token required by privileged operations such as revealing secrets")
 
 	flag.Parse()
 }
 
 func main() {
+	// 收到退出信号时停止informer和后台任务，关闭web服务
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	k8sConfig, err := client.NewBaseConfig(kubeconfig)
 	if err != nil {
 		logger.Error(err, "create k8s config failed")
@@ -50,12 +87,94 @@ func main() {
 		return
 	}
 
-	apiSvc := &api.ApiServer{DynamicClient: dynamicClient, Log: logger}
-	apiSvc.RunInformerFactory(factory, ctx)
+	discoveryClient, err := client.NewCachedDiscoveryClient(k8sConfig)
+	if err != nil {
+		logger.Error(err, "Create discoveryClient failed")
+		return
+	}
 
-	err = http.ListenAndServe(":9898", apiSvc.Engine())
+	metadataClient, err := client.NewMetadataClient(k8sConfig)
 	if err != nil {
-		logger.Error(err, "Started web server")
+		logger.Error(err, "Create metadataClient failed")
+		return
+	}
+
+	db, err := store.Open(filepath.Join(*dataDir, "easy-k8s.db"))
+	if err != nil {
+		logger.Error(err, "Open local store failed")
+		return
+	}
+	defer db.Close()
+
+	usageConf, err := usage.LoadConfig(*usageConfig)
+	if err != nil {
+		logger.Error(err, "Load usage config failed")
+		return
+	}
+
+	promConf, err := prometheus.LoadConfig(*prometheusConfig)
+	if err != nil {
+		logger.Error(err, "Load prometheus config failed")
 		return
 	}
+	if len(*prometheusAddr) != 0 {
+		promConf.Address = *prometheusAddr
+	}
+
+	nsTemplate, err := nstemplate.Load(*namespaceTmpl)
+	if err != nil {
+		logger.Error(err, "Load namespace template failed")
+		return
+	}
+
+	alertConf, err := alert.LoadConfig(*alertConfig)
+	if err != nil {
+		logger.Error(err, "Load alert config failed")
+		return
+	}
+	alerter, err := alert.NewAlerter(logger, alertConf)
+	if err != nil {
+		logger.Error(err, "Create alerter failed")
+		return
+	}
+
+	apiSvc := &api.ApiServer{
+		DynamicClient:        dynamicClient,
+		Discovery:            discoveryClient,
+		MetadataClient:       metadataClient,
+		Applier:              apply.NewApplier(dynamicClient, discoveryClient, *fieldManager),
+		Metrics:              metrics.NewProvider(dynamicClient),
+		Log:                  logger,
+		AdminToken:           *adminToken,
+		Store:                db,
+		UsageConfig:          usageConf,
+		PrometheusConfig:     promConf,
+		NamespaceTemplate:    nsTemplate,
+		EventRetention:       *eventRetention,
+		EventCapacity:        *eventCapacity,
+		Alerter:              alerter,
+		NodeHistoryRetention: *nodeHistoryTTL,
+	}
+	background := apiSvc.RunInformerFactory(factory, ctx)
+
+	server := &http.Server{Addr: ":9898", Handler: apiSvc.Engine()}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error(err, "Shutdown web server")
+		}
+	}()
+	err = server.ListenAndServe()
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err, "Started web server")
+		stop()
+	}
+	// 等待处理中的请求结束
+	<-shutdown
+	// 等待后台任务写完数据后再关闭store
+	background.Wait()
 }
@@ -0,0 +1,372 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+
+	"easy-k8s/pkg/comm"
+	eresource "easy-k8s/pkg/k8s/resource"
+)
+
+const (
+	reasonOOMKilled = "OOMKilled"
+	// 待发送队列长度，队列满时丢弃告警
+	queueSize = 1000
+)
+
+// Alerter 根据node、pod、event informer的更新评估告警规则，去重和限流后发送到webhook
+type Alerter struct {
+	log         logr.Logger
+	conf        *Config
+	podInformer cache.SharedIndexInformer
+	webhooks    map[string]*webhook
+	limiters    map[string]flowcontrol.RateLimiter
+	queue       chan *Alert
+	// 启动前已经存在的事件不告警
+	startTime time.Time
+	// restartWindow 重启规则中最长的时间窗口，重启记录保留该时长
+	restartWindow time.Duration
+
+	lock     sync.Mutex
+	lastSent map[string]time.Time
+	// 每个容器最近的重启时间，key为 podUID/容器名
+	restarts map[string][]time.Time
+}
+
+func NewAlerter(log logr.Logger, conf *Config) (*Alerter, error) {
+	a := &Alerter{
+		log:       log.WithName("Alerter"),
+		conf:      conf,
+		webhooks:  make(map[string]*webhook, len(conf.Targets)),
+		limiters:  make(map[string]flowcontrol.RateLimiter, len(conf.Targets)),
+		queue:     make(chan *Alert, queueSize),
+		startTime: time.Now(),
+		lastSent:  make(map[string]time.Time),
+		restarts:  make(map[string][]time.Time),
+	}
+	for _, target := range conf.Targets {
+		hook, err := newWebhook(target)
+		if err != nil {
+			return nil, err
+		}
+		a.webhooks[target.Name] = hook
+		a.limiters[target.Name] = flowcontrol.NewTokenBucketRateLimiter(float32(conf.RateLimit)/60, conf.RateLimit)
+	}
+	for _, rule := range conf.Rules {
+		if rule.Type == RulePodRestart && rule.RestartWindow.Duration > a.restartWindow {
+			a.restartWindow = rule.RestartWindow.Duration
+		}
+	}
+	return a, nil
+}
+
+// Enabled 没有配置webhook时不需要评估规则
+func (a *Alerter) Enabled() bool {
+	return len(a.webhooks) != 0
+}
+
+// Register 在informer启动前注册回调，pod informer同时用于判断事件关联的pod是否申请了GPU
+func (a *Alerter) Register(nodeInformer, podInformer, eventInformer cache.SharedIndexInformer) {
+	a.podInformer = podInformer
+	nodeInformer.AddEventHandler(a.nodeHandler())
+	podInformer.AddEventHandler(a.podHandler())
+	eventInformer.AddEventHandler(a.eventHandler())
+}
+
+// Run 消费告警队列并发送
+func (a *Alerter) Run(ctx context.Context) {
+	a.log.Info("STARTING alerter", "targets", len(a.webhooks), "rules", len(a.conf.Rules))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-a.queue:
+			a.send(ctx, alert)
+		}
+	}
+}
+
+func (a *Alerter) nodeHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldNode, ok1 := oldObj.(*v1.Node)
+			newNode, ok2 := newObj.(*v1.Node)
+			if ok1 && ok2 {
+				a.evaluateNode(oldNode, newNode)
+			}
+		},
+	}
+}
+
+func (a *Alerter) podHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj any) {
+			oldPod, ok1 := oldObj.(*v1.Pod)
+			newPod, ok2 := newObj.(*v1.Pod)
+			if ok1 && ok2 {
+				a.evaluatePod(oldPod, newPod)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*v1.Pod); ok {
+				a.forgetPod(pod.UID)
+			}
+		},
+	}
+}
+
+func (a *Alerter) eventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if event, ok := obj.(*eventsv1.Event); ok {
+				a.evaluateEvent(event)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldEvent, ok1 := oldObj.(*eventsv1.Event)
+			newEvent, ok2 := newObj.(*eventsv1.Event)
+			if ok1 && ok2 && oldEvent.ResourceVersion != newEvent.ResourceVersion {
+				a.evaluateEvent(newEvent)
+			}
+		},
+	}
+}
+
+// evaluateNode condition状态发生变化时告警，SendResolved的规则在恢复时也通知
+func (a *Alerter) evaluateNode(oldNode, newNode *v1.Node) {
+	for _, rule := range a.conf.Rules {
+		if rule.Type != RuleNodeCondition {
+			continue
+		}
+		oldCondition := nodeCondition(oldNode, rule.Condition)
+		newCondition := nodeCondition(newNode, rule.Condition)
+		if newCondition == nil || (oldCondition != nil && oldCondition.Status == newCondition.Status) {
+			continue
+		}
+		firing := slices.Contains(rule.Statuses, string(newCondition.Status))
+		wasFiring := oldCondition != nil && slices.Contains(rule.Statuses, string(oldCondition.Status))
+		if !firing && !(wasFiring && rule.SendResolved) {
+			continue
+		}
+		a.fire(rule, &Alert{
+			Kind:     "Node",
+			Name:     newNode.Name,
+			Reason:   rule.Condition + "=" + string(newCondition.Status),
+			Message:  newCondition.Reason + ": " + newCondition.Message,
+			Resolved: !firing,
+		})
+	}
+}
+
+// evaluatePod 比较容器的重启次数和终止原因，判断OOM和重启激增
+func (a *Alerter) evaluatePod(oldPod, newPod *v1.Pod) {
+	oldStatuses := make(map[string]v1.ContainerStatus)
+	for _, status := range slices.Concat(oldPod.Status.InitContainerStatuses, oldPod.Status.ContainerStatuses) {
+		oldStatuses[status.Name] = status
+	}
+	now := time.Now()
+	for _, status := range slices.Concat(newPod.Status.InitContainerStatuses, newPod.Status.ContainerStatuses) {
+		old, ok := oldStatuses[status.Name]
+		if !ok {
+			continue
+		}
+		restarted := status.RestartCount - old.RestartCount
+		// 每个容器每次更新只记录一次重启，各规则再按自己的时间窗口计数
+		var restartTimes []time.Time
+		if restarted > 0 {
+			restartTimes = a.recordRestarts(newPod.UID, status.Name, int(restarted), now)
+		}
+
+		// 重启后上一次终止的原因，或restartPolicy为Never时当前的终止原因
+		oomKilled := restarted > 0 && status.LastTerminationState.Terminated != nil && status.LastTerminationState.Terminated.Reason == reasonOOMKilled
+		if status.State.Terminated != nil && status.State.Terminated.Reason == reasonOOMKilled && old.State.Terminated == nil {
+			oomKilled = true
+		}
+
+		for _, rule := range a.conf.Rules {
+			if len(rule.Namespaces) != 0 && !slices.Contains(rule.Namespaces, newPod.Namespace) {
+				continue
+			}
+			switch {
+			case rule.Type == RuleOOMKilled && oomKilled:
+				a.fire(rule, &Alert{
+					Kind:      "Pod",
+					Namespace: newPod.Namespace,
+					Name:      newPod.Name,
+					Reason:    reasonOOMKilled,
+					Message:   "container " + status.Name + " was OOMKilled",
+				})
+			case rule.Type == RulePodRestart && restarted > 0:
+				if count := countSince(restartTimes, now.Add(-rule.RestartWindow.Duration)); count >= rule.RestartThreshold {
+					a.fire(rule, &Alert{
+						Kind:      "Pod",
+						Namespace: newPod.Namespace,
+						Name:      newPod.Name,
+						Reason:    "RestartSpike",
+						Message:   fmt.Sprintf("container %s restarted %d times in %s", status.Name, count, rule.RestartWindow.Duration),
+					})
+				}
+			}
+		}
+	}
+}
+
+func (a *Alerter) evaluateEvent(event *eventsv1.Event) {
+	lastTime := event.EventTime.Time
+	if event.Series != nil {
+		lastTime = event.Series.LastObservedTime.Time
+	} else if !event.DeprecatedLastTimestamp.IsZero() {
+		lastTime = event.DeprecatedLastTimestamp.Time
+	}
+	if lastTime.Before(a.startTime) {
+		return
+	}
+
+	for _, rule := range a.conf.Rules {
+		if rule.Type != RuleEvent {
+			continue
+		}
+		if len(rule.EventType) != 0 && rule.EventType != event.Type {
+			continue
+		}
+		if len(rule.Reasons) != 0 && !slices.Contains(rule.Reasons, event.Reason) {
+			continue
+		}
+		if len(rule.Kinds) != 0 && !slices.Contains(rule.Kinds, event.Regarding.Kind) {
+			continue
+		}
+		if len(rule.Namespaces) != 0 && !slices.Contains(rule.Namespaces, event.Regarding.Namespace) {
+			continue
+		}
+		if rule.GpuOnly && !a.gpuPod(event.Regarding.Kind, event.Regarding.Namespace, event.Regarding.Name) {
+			continue
+		}
+		a.fire(rule, &Alert{
+			Kind:      event.Regarding.Kind,
+			Namespace: event.Regarding.Namespace,
+			Name:      event.Regarding.Name,
+			Reason:    event.Reason,
+			Message:   event.Note,
+		})
+	}
+}
+
+// fire 去重后放入发送队列
+func (a *Alerter) fire(rule *Rule, alert *Alert) {
+	alert.Rule, alert.Severity, alert.Time, alert.targets = rule.Name, rule.Severity, time.Now(), rule.Targets
+
+	a.lock.Lock()
+	key := alert.key()
+	if last, ok := a.lastSent[key]; ok && alert.Time.Sub(last) < a.conf.DedupWindow.Duration {
+		a.lock.Unlock()
+		return
+	}
+	a.lastSent[key] = alert.Time
+	// 状态变化后清除相反状态的去重记录，反复变化的对象每次变化都会通知
+	delete(a.lastSent, alert.stateKey(!alert.Resolved))
+	// 清理过期的去重记录
+	for k, last := range a.lastSent {
+		if alert.Time.Sub(last) >= a.conf.DedupWindow.Duration {
+			delete(a.lastSent, k)
+		}
+	}
+	a.lock.Unlock()
+
+	select {
+	case a.queue <- alert:
+	default:
+		a.log.Info("alert queue is full, drop alert", "rule", alert.Rule, "name", alert.Name)
+	}
+}
+
+func (a *Alerter) send(ctx context.Context, alert *Alert) {
+	for name, hook := range a.webhooks {
+		if len(alert.targets) != 0 && !slices.Contains(alert.targets, name) {
+			continue
+		}
+		if !a.limiters[name].TryAccept() {
+			a.log.Info("alert rate limited", "target", name, "rule", alert.Rule, "name", alert.Name)
+			continue
+		}
+		if err := hook.send(ctx, alert); err != nil {
+			a.log.Error(err, "send alert err", "target", name, "rule", alert.Rule)
+		}
+	}
+}
+
+// recordRestarts 记录重启并返回最长窗口内的重启时间
+func (a *Alerter) recordRestarts(uid types.UID, container string, restarted int, now time.Time) []time.Time {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	key := string(uid) + "/" + container
+	var recent []time.Time
+	for _, t := range a.restarts[key] {
+		if now.Sub(t) < a.restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	for i := 0; i < restarted; i++ {
+		recent = append(recent, now)
+	}
+	a.restarts[key] = recent
+	return recent
+}
+
+// countSince 返回since之后的重启次数
+func countSince(times []time.Time, since time.Time) int {
+	count := 0
+	for _, t := range times {
+		if t.After(since) {
+			count++
+		}
+	}
+	return count
+}
+
+func (a *Alerter) forgetPod(uid types.UID) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	prefix := string(uid) + "/"
+	for key := range a.restarts {
+		if strings.HasPrefix(key, prefix) {
+			delete(a.restarts, key)
+		}
+	}
+}
+
+func (a *Alerter) gpuPod(kind, namespace, name string) bool {
+	if kind != "Pod" {
+		return false
+	}
+	obj, exists, err := a.podInformer.GetStore().GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return false
+	}
+	reqs, _ := eresource.PodRequestsAndLimits(obj.(*v1.Pod))
+	gpu, ok := reqs[comm.LabelNVIDIA]
+	return ok && !gpu.IsZero()
+}
+
+func nodeCondition(node *v1.Node, conditionType string) *v1.NodeCondition {
+	for i := range node.Status.Conditions {
+		if string(node.Status.Conditions[i].Type) == conditionType {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package alert
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+)
+
+func newTestAlerter(t *testing.T, rules ...*Rule) *Alerter {
+	t.Helper()
+	conf := &Config{DedupWindow: metav1.Duration{Duration: time.Hour}, RateLimit: 10, Rules: rules}
+	a, err := NewAlerter(logr.Discard(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// drain 取出队列中所有待发送的告警
+func drain(a *Alerter) []*Alert {
+	var alerts []*Alert
+	for {
+		select {
+		case alert := <-a.queue:
+			alerts = append(alerts, alert)
+		default:
+			return alerts
+		}
+	}
+}
+
+func TestFireDedup(t *testing.T) {
+	a := newTestAlerter(t)
+	rule := &Rule{Name: "node-not-ready", Type: RuleNodeCondition}
+	fire := func(resolved bool) {
+		a.fire(rule, &Alert{Kind: "Node", Name: "node-1", Resolved: resolved})
+	}
+
+	// 重复的告警只发送一次
+	fire(false)
+	fire(false)
+	if n := len(a.queue); n != 1 {
+		t.Fatalf("queued %d alerts, want 1", n)
+	}
+
+	// 去重时间内反复恢复和告警，每次状态变化都发送
+	fire(true)
+	fire(false)
+	fire(true)
+	if n := len(a.queue); n != 4 {
+		t.Errorf("queued %d alerts after flapping, want 4", n)
+	}
+}
+
+func conditionNode(conditions map[v1.NodeConditionType]v1.ConditionStatus) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}
+	for conditionType, status := range conditions {
+		node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: conditionType, Status: status, Reason: "KubeletStatus"})
+	}
+	return node
+}
+
+func TestEvaluateNode(t *testing.T) {
+	a := newTestAlerter(t,
+		&Rule{Name: "NodeNotReady", Type: RuleNodeCondition, Condition: "Ready", Statuses: []string{"False", "Unknown"}, SendResolved: true},
+		&Rule{Name: "NodePressure", Type: RuleNodeCondition, Condition: "MemoryPressure", Statuses: []string{"True"}},
+	)
+	handler := a.nodeHandler()
+
+	tests := []struct {
+		name       string
+		old, new   map[v1.NodeConditionType]v1.ConditionStatus
+		wantRule   string
+		wantReason string
+		resolved   bool
+	}{
+		{"not ready", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionTrue}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionFalse}, "NodeNotReady", "Ready=False", false},
+		{"status unchanged", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionFalse}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionFalse}, "", "", false},
+		// 仍在告警状态，去重时间内不重复发送
+		{"still firing", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionFalse}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionUnknown}, "", "", false},
+		{"resolved", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionUnknown}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionTrue}, "NodeNotReady", "Ready=True", true},
+		{"condition removed", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeReady: v1.ConditionTrue}, nil, "", "", false},
+		{"pressure", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeMemoryPressure: v1.ConditionFalse}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeMemoryPressure: v1.ConditionTrue}, "NodePressure", "MemoryPressure=True", false},
+		// 未配置SendResolved时恢复不通知
+		{"pressure resolved", map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeMemoryPressure: v1.ConditionTrue}, map[v1.NodeConditionType]v1.ConditionStatus{v1.NodeMemoryPressure: v1.ConditionFalse}, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.OnUpdate(conditionNode(tt.old), conditionNode(tt.new))
+			alerts := drain(a)
+			if len(tt.wantRule) == 0 {
+				if len(alerts) != 0 {
+					t.Errorf("alerts = %+v, want none", alerts[0])
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			alert := alerts[0]
+			if alert.Rule != tt.wantRule || alert.Reason != tt.wantReason || alert.Resolved != tt.resolved || alert.Kind != "Node" || alert.Name != "node-1" {
+				t.Errorf("alert = %+v, want %s %s resolved=%t", alert, tt.wantRule, tt.wantReason, tt.resolved)
+			}
+		})
+	}
+}
+
+func containerPod(namespace, name string, status v1.ContainerStatus) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + "/" + name)},
+		Status:     v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{status}},
+	}
+}
+
+func running(restartCount int32) v1.ContainerStatus {
+	return v1.ContainerStatus{Name: "app", RestartCount: restartCount, State: v1.ContainerState{Running: &v1.ContainerStateRunning{}}}
+}
+
+func restartedAfter(restartCount int32, reason string) v1.ContainerStatus {
+	status := running(restartCount)
+	status.LastTerminationState.Terminated = &v1.ContainerStateTerminated{Reason: reason}
+	return status
+}
+
+func TestOOMKilled(t *testing.T) {
+	a := newTestAlerter(t, &Rule{Name: "PodOOMKilled", Type: RuleOOMKilled, Namespaces: []string{"default", "batch"}})
+	handler := a.podHandler()
+
+	terminated := v1.ContainerStatus{Name: "app", State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{Reason: reasonOOMKilled}}}
+	tests := []struct {
+		name     string
+		old, new *v1.Pod
+		want     bool
+	}{
+		{"restarted after oom", containerPod("default", "web", running(0)), containerPod("default", "web", restartedAfter(1, reasonOOMKilled)), true},
+		{"restarted after error", containerPod("default", "api", running(0)), containerPod("default", "api", restartedAfter(1, "Error")), false},
+		// restartPolicy为Never时不会重启，只有当前状态
+		{"terminated by oom", containerPod("batch", "job", running(0)), containerPod("batch", "job", terminated), true},
+		{"already terminated", containerPod("batch", "done", terminated), containerPod("batch", "done", terminated), false},
+		{"namespace not matched", containerPod("kube-system", "dns", running(0)), containerPod("kube-system", "dns", restartedAfter(1, reasonOOMKilled)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.OnUpdate(tt.old, tt.new)
+			alerts := drain(a)
+			if !tt.want {
+				if len(alerts) != 0 {
+					t.Errorf("alerts = %+v, want none", alerts[0])
+				}
+				return
+			}
+			if len(alerts) != 1 || alerts[0].Reason != reasonOOMKilled || alerts[0].Name != tt.new.Name || alerts[0].Namespace != tt.new.Namespace {
+				t.Fatalf("alerts = %+v, want one OOMKilled alert for %s", alerts, tt.new.Name)
+			}
+		})
+	}
+}
+
+func TestRestartSpike(t *testing.T) {
+	a := newTestAlerter(t,
+		&Rule{Name: "Fast", Type: RulePodRestart, RestartThreshold: 2, RestartWindow: metav1.Duration{Duration: time.Minute}},
+		&Rule{Name: "Slow", Type: RulePodRestart, RestartThreshold: 4, RestartWindow: metav1.Duration{Duration: time.Hour}},
+	)
+	handler := a.podHandler()
+	restart := func(from, to int32) []string {
+		handler.OnUpdate(containerPod("default", "web", running(from)), containerPod("default", "web", running(to)))
+		var rules []string
+		for _, alert := range drain(a) {
+			rules = append(rules, alert.Rule)
+		}
+		return rules
+	}
+
+	// 两条规则各自计数，同一次重启不会被记录两次
+	if rules := restart(0, 1); len(rules) != 0 {
+		t.Fatalf("alerts after 1 restart = %v, want none", rules)
+	}
+	if rules := restart(1, 2); len(rules) != 1 || rules[0] != "Fast" {
+		t.Fatalf("alerts after 2 restarts = %v, want [Fast]", rules)
+	}
+	if rules := restart(2, 3); len(rules) != 0 {
+		t.Fatalf("alerts after 3 restarts = %v, want none (Fast deduped)", rules)
+	}
+	if rules := restart(3, 4); len(rules) != 1 || rules[0] != "Slow" {
+		t.Fatalf("alerts after 4 restarts = %v, want [Slow]", rules)
+	}
+
+	// 较短窗口的规则不会清理较长窗口仍需要的重启记录
+	a = newTestAlerter(t, a.conf.Rules...)
+	handler = a.podHandler()
+	key := "default/web/app"
+	a.restarts[key] = []time.Time{time.Now().Add(-30 * time.Minute), time.Now().Add(-20 * time.Minute), time.Now().Add(-10 * time.Minute)}
+	if rules := restart(3, 4); len(rules) != 1 || rules[0] != "Slow" {
+		t.Fatalf("alerts = %v, want [Slow]", rules)
+	}
+	if n := len(a.restarts[key]); n != 4 {
+		t.Errorf("kept %d restarts, want 4", n)
+	}
+
+	// pod删除后清理重启记录
+	handler.OnDelete(containerPod("default", "web", running(4)))
+	if len(a.restarts) != 0 {
+		t.Errorf("restarts = %v, want none after pod deleted", a.restarts)
+	}
+}
+
+func newTestPodInformer(t *testing.T, objs ...runtime.Object) cache.SharedIndexInformer {
+	t.Helper()
+	informer := cache.NewSharedIndexInformer(nil, &v1.Pod{}, 0, cache.Indexers{})
+	for _, obj := range objs {
+		if err := informer.GetStore().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return informer
+}
+
+func requestPod(name string, requests v1.ResourceList) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app", Resources: v1.ResourceRequirements{Requests: requests}}}},
+	}
+}
+
+func schedulingEvent(kind, name, eventType string, eventTime time.Time) *eventsv1.Event {
+	return &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name + ".1", ResourceVersion: "1"},
+		EventTime:  metav1.NewMicroTime(eventTime),
+		Type:       eventType,
+		Reason:     "FailedScheduling",
+		Note:       "0/3 nodes are available",
+		Regarding:  v1.ObjectReference{Kind: kind, Namespace: "default", Name: name},
+	}
+}
+
+func TestGpuFailedScheduling(t *testing.T) {
+	a := newTestAlerter(t, &Rule{Name: "GpuPodFailedScheduling", Type: RuleEvent, EventType: "Warning", Reasons: []string{"FailedScheduling"}, Kinds: []string{"Pod"}, GpuOnly: true})
+	a.podInformer = newTestPodInformer(t,
+		requestPod("train", v1.ResourceList{comm.LabelNVIDIA: resource.MustParse("1")}),
+		requestPod("web", v1.ResourceList{v1.ResourceCPU: resource.MustParse("1")}),
+	)
+	handler := a.eventHandler()
+	now := time.Now()
+
+	tests := []struct {
+		name  string
+		event *eventsv1.Event
+		want  bool
+	}{
+		{"gpu pod", schedulingEvent("Pod", "train", "Warning", now), true},
+		{"cpu pod", schedulingEvent("Pod", "web", "Warning", now), false},
+		{"pod not in cache", schedulingEvent("Pod", "missing", "Warning", now), false},
+		{"not a pod", schedulingEvent("Deployment", "train", "Warning", now), false},
+		{"normal event", schedulingEvent("Pod", "train", "Normal", now), false},
+		// 启动前的事件不告警
+		{"before start", schedulingEvent("Pod", "train", "Warning", a.startTime.Add(-time.Minute)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.OnAdd(tt.event, false)
+			alerts := drain(a)
+			if !tt.want {
+				if len(alerts) != 0 {
+					t.Errorf("alerts = %+v, want none", alerts[0])
+				}
+				return
+			}
+			if len(alerts) != 1 || alerts[0].Name != "train" || alerts[0].Reason != "FailedScheduling" || alerts[0].Message != tt.event.Note {
+				t.Fatalf("alerts = %+v, want one FailedScheduling alert for train", alerts)
+			}
+		})
+	}
+}
+
+func TestSendRateLimit(t *testing.T) {
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer server.Close()
+
+	conf := &Config{
+		DedupWindow: metav1.Duration{Duration: time.Hour},
+		RateLimit:   2,
+		Targets:     []*Target{{Name: "ops", URL: server.URL, Format: FormatGeneric}, {Name: "dev", URL: server.URL, Format: FormatGeneric}},
+	}
+	a, err := NewAlerter(logr.Discard(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个webhook每分钟最多发送RateLimit条
+	for i := 0; i < 5; i++ {
+		a.send(context.Background(), &Alert{Kind: "Node", Name: "node-1", targets: []string{"ops"}})
+	}
+	if n := received.Load(); n != 2 {
+		t.Errorf("ops received %d alerts, want 2", n)
+	}
+	// 限流按webhook独立计算
+	a.send(context.Background(), &Alert{Kind: "Node", Name: "node-1", targets: []string{"dev"}})
+	if n := received.Load(); n != 3 {
+		t.Errorf("received %d alerts, want 3", n)
+	}
+}
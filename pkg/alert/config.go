@@ -0,0 +1,155 @@
+package alert
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// 规则类型
+const (
+	// RuleNodeCondition 节点condition变为指定状态，如 Ready 变为 False/Unknown
+	RuleNodeCondition = "nodeCondition"
+	// RulePodRestart 容器在时间窗口内重启次数达到阈值
+	RulePodRestart = "podRestart"
+	// RuleOOMKilled 容器因OOM被杀
+	RuleOOMKilled = "oomKilled"
+	// RuleEvent 匹配类型、原因和对象类型的事件，如GPU pod的FailedScheduling
+	RuleEvent = "event"
+)
+
+// webhook请求体预设格式
+const (
+	FormatGeneric  = "generic"
+	FormatSlack    = "slack"
+	FormatWeCom    = "wecom"
+	FormatDingTalk = "dingtalk"
+)
+
+// Config 告警规则与webhook配置
+type Config struct {
+	// DedupWindow 同一对象的同一告警在该时间内只发送一次
+	DedupWindow metav1.Duration `json:"dedupWindow"`
+	// RateLimit 每个webhook每分钟最多发送的告警数
+	RateLimit int       `json:"rateLimit"`
+	Targets   []*Target `json:"targets"`
+	Rules     []*Rule   `json:"rules"`
+}
+
+// Target webhook目标，Template为空时按Format使用预设的请求体
+type Target struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Format  string            `json:"format"`
+	Headers map[string]string `json:"headers"`
+	// Template 请求体的Go模板，参数为 .Alert 和渲染好的文本 .Text，json函数用于转义
+	Template string          `json:"template"`
+	Timeout  metav1.Duration `json:"timeout"`
+}
+
+type Rule struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	// Targets 发送的webhook名称，为空时发送到所有webhook
+	Targets []string `json:"targets"`
+	// Namespaces pod和事件规则限定的namespace，为空时不限制
+	Namespaces []string `json:"namespaces"`
+	// SendResolved 节点condition恢复时也发送通知
+	SendResolved bool `json:"sendResolved"`
+
+	// nodeCondition
+	Condition string   `json:"condition"`
+	Statuses  []string `json:"statuses"`
+
+	// podRestart
+	RestartThreshold int             `json:"restartThreshold"`
+	RestartWindow    metav1.Duration `json:"restartWindow"`
+
+	// event
+	EventType string   `json:"eventType"`
+	Reasons   []string `json:"reasons"`
+	Kinds     []string `json:"kinds"`
+	// GpuOnly 只匹配申请了GPU的pod的事件
+	GpuOnly bool `json:"gpuOnly"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		DedupWindow: metav1.Duration{Duration: 30 * time.Minute},
+		RateLimit:   20,
+		Rules: []*Rule{
+			{Name: "NodeNotReady", Type: RuleNodeCondition, Severity: "critical", Condition: "Ready", Statuses: []string{"False", "Unknown"}, SendResolved: true},
+			{Name: "NodePressure", Type: RuleNodeCondition, Severity: "warning", Condition: "MemoryPressure", Statuses: []string{"True"}},
+			{Name: "NodeDiskPressure", Type: RuleNodeCondition, Severity: "warning", Condition: "DiskPressure", Statuses: []string{"True"}},
+			{Name: "PodOOMKilled", Type: RuleOOMKilled, Severity: "warning"},
+			{Name: "PodRestartSpike", Type: RulePodRestart, Severity: "warning", RestartThreshold: 3, RestartWindow: metav1.Duration{Duration: 10 * time.Minute}},
+			{Name: "GpuPodFailedScheduling", Type: RuleEvent, Severity: "warning", EventType: "Warning", Reasons: []string{"FailedScheduling"}, Kinds: []string{"Pod"}, GpuOnly: true},
+		},
+	}
+}
+
+// LoadConfig 从yaml文件加载配置，path为空时使用默认规则且不配置webhook，即不发送告警
+func LoadConfig(path string) (*Config, error) {
+	conf := DefaultConfig()
+	if len(path) == 0 {
+		return conf, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	if conf.DedupWindow.Duration <= 0 {
+		conf.DedupWindow.Duration = 30 * time.Minute
+	}
+	if conf.RateLimit <= 0 {
+		conf.RateLimit = 20
+	}
+	return conf, conf.validate()
+}
+
+func (c *Config) validate() error {
+	targets := make(map[string]struct{}, len(c.Targets))
+	for _, target := range c.Targets {
+		if len(target.Name) == 0 || len(target.URL) == 0 {
+			return fmt.Errorf("alert target requires name and url")
+		}
+		if len(target.Format) == 0 {
+			target.Format = FormatGeneric
+		}
+		if _, ok := presetTemplates[target.Format]; !ok && len(target.Template) == 0 {
+			return fmt.Errorf("alert target %s: unknown format %q", target.Name, target.Format)
+		}
+		if target.Timeout.Duration <= 0 {
+			target.Timeout.Duration = 10 * time.Second
+		}
+		targets[target.Name] = struct{}{}
+	}
+	for _, rule := range c.Rules {
+		switch rule.Type {
+		case RuleNodeCondition:
+			if len(rule.Condition) == 0 || len(rule.Statuses) == 0 {
+				return fmt.Errorf("alert rule %s: condition and statuses are required", rule.Name)
+			}
+		case RulePodRestart:
+			if rule.RestartThreshold <= 0 || rule.RestartWindow.Duration <= 0 {
+				return fmt.Errorf("alert rule %s: restartThreshold and restartWindow are required", rule.Name)
+			}
+		case RuleOOMKilled, RuleEvent:
+		default:
+			return fmt.Errorf("alert rule %s: unknown type %q", rule.Name, rule.Type)
+		}
+		for _, name := range rule.Targets {
+			if _, ok := targets[name]; !ok {
+				return fmt.Errorf("alert rule %s: unknown target %s", rule.Name, name)
+			}
+		}
+	}
+	return nil
+}
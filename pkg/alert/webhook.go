@@ -0,0 +1,114 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Alert 一条告警通知
+type Alert struct {
+	Rule      string    `json:"rule"`
+	Severity  string    `json:"severity"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message"`
+	Resolved  bool      `json:"resolved"`
+	Time      time.Time `json:"time"`
+	// targets 为空时发送到所有webhook
+	targets []string
+}
+
+// templateData webhook模板参数
+type templateData struct {
+	Alert *Alert
+	Text  string
+}
+
+var presetTemplates = map[string]string{
+	FormatGeneric:  `{{ json .Alert }}`,
+	FormatSlack:    `{"text": {{ json .Text }}}`,
+	FormatWeCom:    `{"msgtype": "text", "text": {"content": {{ json .Text }}}}`,
+	FormatDingTalk: `{"msgtype": "text", "text": {"content": {{ json .Text }}}}`,
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// webhook 渲染请求体并发送到一个目标
+type webhook struct {
+	target   *Target
+	template *template.Template
+	client   *http.Client
+}
+
+func newWebhook(target *Target) (*webhook, error) {
+	text := target.Template
+	if len(text) == 0 {
+		text = presetTemplates[target.Format]
+	}
+	tmpl, err := template.New(target.Name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("alert target %s: %w", target.Name, err)
+	}
+	return &webhook{target: target, template: tmpl, client: &http.Client{Timeout: target.Timeout.Duration}}, nil
+}
+
+func (w *webhook) send(ctx context.Context, alert *Alert) error {
+	var body bytes.Buffer
+	if err := w.template.Execute(&body, &templateData{Alert: alert, Text: alert.Text()}); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.target.URL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.target.Headers {
+		req.Header.Set(key, value)
+	}
+	rsp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1024))
+		return fmt.Errorf("webhook %s returned %d: %s", w.target.Name, rsp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Text 用于IM消息的纯文本内容
+func (a *Alert) Text() string {
+	status := "FIRING"
+	if a.Resolved {
+		status = "RESOLVED"
+	}
+	object := a.Kind + " " + a.Name
+	if len(a.Namespace) != 0 {
+		object = a.Kind + " " + a.Namespace + "/" + a.Name
+	}
+	return fmt.Sprintf("[%s][%s] %s\n%s: %s\n%s\n%s", status, a.Severity, a.Rule, object, a.Reason, a.Message, a.Time.Format(time.DateTime))
+}
+
+// key 去重使用的key，告警和恢复分别去重
+func (a *Alert) key() string {
+	return a.stateKey(a.Resolved)
+}
+
+func (a *Alert) stateKey(resolved bool) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%t", a.Rule, a.Kind, a.Namespace, a.Name, resolved)
+}
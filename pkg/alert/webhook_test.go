@@ -0,0 +1,92 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPresetPayloads(t *testing.T) {
+	alert := &Alert{
+		Rule:      "PodOOMKilled",
+		Severity:  "warning",
+		Kind:      "Pod",
+		Namespace: "default",
+		Name:      "web",
+		Reason:    reasonOOMKilled,
+		Message:   `container "app" was OOMKilled`,
+		Time:      time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local),
+	}
+	text := "[FIRING][warning] PodOOMKilled\nPod default/web: OOMKilled\ncontainer \"app\" was OOMKilled\n2024-01-01 10:00:00"
+	if alert.Text() != text {
+		t.Fatalf("text = %q, want %q", alert.Text(), text)
+	}
+
+	im := map[string]any{"msgtype": "text", "text": map[string]any{"content": text}}
+	tests := []struct {
+		format string
+		want   map[string]any
+	}{
+		{FormatGeneric, map[string]any{
+			"rule": "PodOOMKilled", "severity": "warning", "kind": "Pod", "namespace": "default", "name": "web",
+			"reason": reasonOOMKilled, "message": alert.Message, "resolved": false, "time": alert.Time.Format(time.RFC3339),
+		}},
+		{FormatSlack, map[string]any{"text": text}},
+		{FormatWeCom, im},
+		{FormatDingTalk, im},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var body []byte
+			var header http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				body, _ = io.ReadAll(r.Body)
+			}))
+			defer server.Close()
+
+			hook, err := newWebhook(&Target{Name: tt.format, URL: server.URL, Format: tt.format, Headers: map[string]string{"X-Token": "secret"}, Timeout: metav1.Duration{Duration: time.Second}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = hook.send(context.Background(), alert); err != nil {
+				t.Fatal(err)
+			}
+
+			if header.Get("Content-Type") != "application/json" || header.Get("X-Token") != "secret" {
+				t.Errorf("headers = %v", header)
+			}
+			var got map[string]any
+			if err = json.Unmarshal(body, &got); err != nil {
+				t.Fatalf("invalid json %s: %v", body, err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(tt.want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("payload = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	hook, err := newWebhook(&Target{Name: "ops", URL: server.URL, Format: FormatSlack, Timeout: metav1.Duration{Duration: time.Second}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hook.send(context.Background(), &Alert{Kind: "Node", Name: "node-1"})
+	if err == nil || err.Error() != "webhook ops returned 403: invalid token" {
+		t.Errorf("err = %v, want 403 with response body", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 var (
 	NodeNotFoundErr = errors.New("node not found")
 	PodNotFoundErr  = errors.New("pod not found")
+	UnauthorizedErr = errors.New("unauthorized")
 )
 
 // k8s resource label
@@ -18,6 +19,7 @@ const (
 	LabelNodeRolePrefix = "node-role.kubernetes.io/"
 	LabelCustomPrefix   = "osgalaxy.io"
 	LabelNVIDIA         = "nvidia.com/gpu"
+	LabelGpuProduct     = "osgalaxy.io-gpu-nvidia.com"
 )
 
 // volume type
@@ -26,6 +28,22 @@ const (
 	VolumeSecret                = "secret"
 	VolumeHostPath              = "hostPath"
 	VolumePersistentVolumeClaim = "persistentVolumeClaim"
+	VolumeEmptyDir              = "emptyDir"
+	VolumeProjected             = "projected"
+	VolumeDownwardAPI           = "downwardAPI"
+	VolumeCSI                   = "csi"
+	VolumeNFS                   = "nfs"
+	VolumeEphemeral             = "ephemeral"
+	VolumeImage                 = "image"
+	VolumeServiceAccountToken   = "serviceAccountToken"
+	VolumeClusterTrustBundle    = "clusterTrustBundle"
+)
+
+// container type
+const (
+	ContainerTypeInit      = "initContainer"
+	ContainerTypeContainer = "container"
+	ContainerTypeEphemeral = "ephemeralContainer"
 )
 
 var DecodeLables = map[string]struct{}{
@@ -40,6 +58,78 @@ var NodeGVR = schema.GroupVersionResource{
 	Resource: "nodes",
 }
 
+var NamespaceGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "namespaces",
+}
+
+var ResourceQuotaGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "resourcequotas",
+}
+
+var LimitRangeGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "limitranges",
+}
+
+var NetworkPolicyGVR = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "networkpolicies",
+}
+
+var RoleBindingGVR = schema.GroupVersionResource{
+	Group:    "rbac.authorization.k8s.io",
+	Version:  "v1",
+	Resource: "rolebindings",
+}
+
+var ConfigMapGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "configmaps",
+}
+
+var SecretGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "secrets",
+}
+
+var EventGVR = schema.GroupVersionResource{
+	Group:    "",
+	Version:  "v1",
+	Resource: "events",
+}
+
+var ReplicaSetGVR = schema.GroupVersionResource{
+	Group:    "apps",
+	Version:  "v1",
+	Resource: "replicasets",
+}
+
+var JobGVR = schema.GroupVersionResource{
+	Group:    "batch",
+	Version:  "v1",
+	Resource: "jobs",
+}
+
+var NodeMetricsGVR = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "nodes",
+}
+
+var PodMetricsGVR = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "pods",
+}
+
 // PatchOperation dynamicClient patch request JSONPatchType
 type PatchOperation struct {
 	Op    string `json:"op"`
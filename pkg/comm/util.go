@@ -24,3 +24,23 @@ func Base64UrlDecode(input string) (string, error) {
 
 	return string(decodedBytes), nil
 }
+
+// Base64StdEncode standard base64 encoding, used for Secret data
+func Base64StdEncode(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Base64StdDecode standard base64 decoding, used for Secret data and ConfigMap binaryData
+func Base64StdDecode(input string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(input)
+}
+
+// NodeGpuProduct get the gpu product from the node label osgalaxy.io-gpu-nvidia.com/<product>
+func NodeGpuProduct(labels map[string]string) string {
+	for key := range labels {
+		if strings.HasPrefix(key, LabelGpuProduct) {
+			return strings.Split(key, "/")[1]
+		}
+	}
+	return ""
+}
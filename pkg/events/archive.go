@@ -0,0 +1,250 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	eventsv1 "k8s.io/api/events/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/store"
+)
+
+const bucketEvents = "events"
+
+// 订阅者消费过慢时丢弃事件，不阻塞informer
+const subscriberBuffer = 256
+
+// 新事件攒批写入的间隔，以及清理过期和超出容量事件的间隔
+const (
+	flushInterval   = time.Second
+	cleanupInterval = 10 * time.Minute
+)
+
+// key为 TimeKey("", LastTime, uid)，时间戳固定8字节
+const timeKeyLen = 8
+
+// Archive 将informer收到的事件写入本地存储，保留时间超过apiserver默认的1小时TTL，
+// 超过retention或capacity的最旧事件会被清理，同时把新事件推送给SSE订阅者
+type Archive struct {
+	log       logr.Logger
+	store     *store.Store
+	retention time.Duration
+	capacity  int
+
+	lock        sync.Mutex
+	subscribers map[chan *Event]struct{}
+	// 等待写入的事件，同一事件在一个批次内的多次更新只写入最新一次
+	pending map[string]*Event
+	// uid到当前key的索引，事件更新时删除旧key，只在Run中访问
+	index map[string][]byte
+}
+
+func NewArchive(log logr.Logger, store *store.Store, retention time.Duration, capacity int) *Archive {
+	return &Archive{
+		log:         log.WithName("EventArchive"),
+		store:       store,
+		retention:   retention,
+		capacity:    capacity,
+		subscribers: make(map[chan *Event]struct{}),
+		pending:     make(map[string]*Event),
+		index:       make(map[string][]byte),
+	}
+}
+
+// Handler 注册到event informer的回调，resync时resourceVersion未变化的事件会被忽略
+func (a *Archive) Handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if event, ok := obj.(*eventsv1.Event); ok {
+				a.Add(FromK8s(event))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldEvent, ok1 := oldObj.(*eventsv1.Event)
+			newEvent, ok2 := newObj.(*eventsv1.Event)
+			if ok1 && ok2 && oldEvent.ResourceVersion != newEvent.ResourceVersion {
+				a.Add(FromK8s(newEvent))
+			}
+		},
+	}
+}
+
+// Add 将事件加入待写入队列并推送给订阅者，由Run以 最后发生时间+uid 为key批量写入，
+// 不在informer的回调中同步写盘
+func (a *Archive) Add(ev *Event) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.pending[ev.UID] = ev
+	for ch := range a.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Query 查询时间窗口内匹配的事件，按最后发生时间倒序，limit<=0时不限制条数
+func (a *Archive) Query(filter *Filter, limit int) ([]*Event, error) {
+	var min, max []byte
+	if !filter.Since.IsZero() {
+		min = store.TimeKey("", filter.Since, "")
+	}
+	if !filter.Until.IsZero() {
+		max = store.TimeKey("", filter.Until, "")
+	}
+
+	latest := make(map[string]*Event)
+	err := a.store.Scan(bucketEvents, min, max, func(key, value []byte) error {
+		ev := &Event{}
+		if err := json.Unmarshal(value, ev); err != nil {
+			return err
+		}
+		if filter.Match(ev) {
+			latest[ev.UID] = ev
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return SortAndLimit(latest, limit), nil
+}
+
+// Subscribe 订阅新事件，调用返回的cancel取消订阅
+func (a *Archive) Subscribe() (<-chan *Event, func()) {
+	ch := make(chan *Event, subscriberBuffer)
+	a.lock.Lock()
+	a.subscribers[ch] = struct{}{}
+	a.lock.Unlock()
+
+	return ch, func() {
+		a.lock.Lock()
+		delete(a.subscribers, ch)
+		a.lock.Unlock()
+	}
+}
+
+// Run 批量写入新事件，定期清理过期和超出容量的事件，ctx结束时写入剩余的事件
+func (a *Archive) Run(ctx context.Context) {
+	a.log.Info("STARTING event archive", "retention", a.retention, "capacity", a.capacity)
+	if err := a.loadIndex(); err != nil {
+		a.log.Error(err, "load event index err")
+	}
+	a.cleanup()
+
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+	cleanupTicker := time.NewTicker(cleanupInterval)
+	defer cleanupTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			a.flush()
+			return
+		case <-flushTicker.C:
+			a.flush()
+		case <-cleanupTicker.C:
+			a.flush()
+			a.cleanup()
+		}
+	}
+}
+
+// flush 在一个事务中写入待写入的事件，并删除这些事件之前的key
+func (a *Archive) flush() {
+	a.lock.Lock()
+	pending := a.pending
+	a.pending = make(map[string]*Event)
+	a.lock.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+
+	puts := make([]store.Entry, 0, len(pending))
+	var deletes [][]byte
+	for uid, ev := range pending {
+		key := store.TimeKey("", ev.LastTime, uid)
+		if old, ok := a.index[uid]; ok && !bytes.Equal(old, key) {
+			deletes = append(deletes, old)
+		}
+		puts = append(puts, store.Entry{Key: key, Value: ev})
+	}
+	if err := a.store.Write(bucketEvents, puts, deletes); err != nil {
+		a.log.Error(err, "archive events err", "count", len(puts))
+		return
+	}
+	for _, entry := range puts {
+		a.index[string(entry.Key[timeKeyLen:])] = entry.Key
+	}
+}
+
+// loadIndex 从存储中重建uid索引，同一uid存在多条记录时只保留最新的一条
+func (a *Archive) loadIndex() error {
+	index := make(map[string][]byte)
+	var deletes [][]byte
+	err := a.store.Scan(bucketEvents, nil, nil, func(key, _ []byte) error {
+		if len(key) <= timeKeyLen {
+			return nil
+		}
+		uid := string(key[timeKeyLen:])
+		// key按时间升序遍历，已有的记录更旧
+		if old, ok := index[uid]; ok {
+			deletes = append(deletes, old)
+		}
+		index[uid] = bytes.Clone(key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(deletes) != 0 {
+		if err = a.store.Write(bucketEvents, nil, deletes); err != nil {
+			return err
+		}
+		a.log.Info("removed duplicated archived events", "count", len(deletes))
+	}
+	a.index = index
+	return nil
+}
+
+func (a *Archive) cleanup() {
+	expired, err := a.store.DeleteBefore(bucketEvents, store.TimeKey("", time.Now().Add(-a.retention), ""))
+	if err != nil {
+		a.log.Error(err, "delete expired events err")
+	}
+	trimmed, err := a.store.TrimOldest(bucketEvents, a.capacity)
+	if err != nil {
+		a.log.Error(err, "trim events err")
+	}
+	if expired+trimmed > 0 {
+		a.log.Info("cleaned archived events", "expired", expired, "trimmed", trimmed)
+		// 清理后索引中可能有已删除的key
+		if err = a.loadIndex(); err != nil {
+			a.log.Error(err, "load event index err")
+		}
+	}
+}
+
+// SortAndLimit 按最后发生时间倒序
+func SortAndLimit(events map[string]*Event, limit int) []*Event {
+	list := make([]*Event, 0, len(events))
+	for _, ev := range events {
+		list = append(list, ev)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].LastTime.Equal(list[j].LastTime) {
+			return list[i].LastTime.After(list[j].LastTime)
+		}
+		return list[i].UID < list[j].UID
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,81 @@
+package events
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	"easy-k8s/pkg/store"
+)
+
+func newTestArchive(t *testing.T) (*Archive, *store.Store) {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewArchive(logr.Discard(), db, time.Hour, 100), db
+}
+
+func countEvents(t *testing.T, db *store.Store) int {
+	t.Helper()
+	count := 0
+	if err := db.Scan(bucketEvents, nil, nil, func(_, _ []byte) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestArchiveReplacesUpdatedEvent(t *testing.T) {
+	archive, db := newTestArchive(t)
+	now := time.Now()
+
+	archive.Add(&Event{UID: "a", Count: 1, LastTime: now.Add(-time.Minute)})
+	archive.flush()
+	archive.Add(&Event{UID: "a", Count: 2, LastTime: now})
+	archive.Add(&Event{UID: "b", Count: 1, LastTime: now})
+	archive.flush()
+
+	if n := countEvents(t, db); n != 2 {
+		t.Errorf("stored %d events, want 2", n)
+	}
+	events, err := archive.Query(&Filter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev.UID == "a" && ev.Count != 2 {
+			t.Errorf("event a count = %d, want latest 2", ev.Count)
+		}
+	}
+}
+
+func TestArchiveLoadIndexRemovesDuplicates(t *testing.T) {
+	archive, db := newTestArchive(t)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		ev := &Event{UID: "a", Count: int32(i), LastTime: now.Add(time.Duration(i) * time.Second)}
+		if err := db.Put(bucketEvents, store.TimeKey("", ev.LastTime, ev.UID), ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := archive.loadIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if n := countEvents(t, db); n != 1 {
+		t.Errorf("stored %d events, want 1", n)
+	}
+
+	archive.Add(&Event{UID: "a", Count: 3, LastTime: now.Add(time.Minute)})
+	archive.flush()
+	if n := countEvents(t, db); n != 1 {
+		t.Errorf("stored %d events after update, want 1", n)
+	}
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	eventsv1 "k8s.io/api/events/v1"
+)
+
+// Event events.k8s.io/v1 Event的扁平化表示，同时用于接口返回和本地存储
+type Event struct {
+	UID                 string    `json:"uid"`
+	Namespace           string    `json:"namespace"`
+	Name                string    `json:"name"`
+	Type                string    `json:"type"`
+	Reason              string    `json:"reason"`
+	Action              string    `json:"action,omitempty"`
+	Note                string    `json:"note"`
+	Kind                string    `json:"kind"`
+	ObjectNamespace     string    `json:"objectNamespace,omitempty"`
+	ObjectName          string    `json:"objectName"`
+	ReportingController string    `json:"reportingController,omitempty"`
+	Count               int32     `json:"count"`
+	FirstTime           time.Time `json:"firstTime"`
+	LastTime            time.Time `json:"lastTime"`
+}
+
+// Filter 事件查询条件，空字段表示不过滤
+type Filter struct {
+	Namespace string
+	Type      string
+	Reason    string
+	Kind      string
+	Name      string
+	Since     time.Time
+	Until     time.Time
+}
+
+// FromK8s 兼容通过core/v1写入的旧事件，时间和次数优先取series
+func FromK8s(event *eventsv1.Event) *Event {
+	ev := &Event{
+		UID:                 string(event.UID),
+		Namespace:           event.Namespace,
+		Name:                event.Name,
+		Type:                event.Type,
+		Reason:              event.Reason,
+		Action:              event.Action,
+		Note:                event.Note,
+		Kind:                event.Regarding.Kind,
+		ObjectNamespace:     event.Regarding.Namespace,
+		ObjectName:          event.Regarding.Name,
+		ReportingController: event.ReportingController,
+		Count:               1,
+	}
+	if len(ev.ReportingController) == 0 {
+		ev.ReportingController = event.DeprecatedSource.Component
+	}
+
+	switch {
+	case !event.EventTime.IsZero():
+		ev.FirstTime = event.EventTime.Time
+	case !event.DeprecatedFirstTimestamp.IsZero():
+		ev.FirstTime = event.DeprecatedFirstTimestamp.Time
+	default:
+		ev.FirstTime = event.CreationTimestamp.Time
+	}
+	ev.LastTime = ev.FirstTime
+
+	if event.Series != nil {
+		ev.Count = event.Series.Count
+		ev.LastTime = event.Series.LastObservedTime.Time
+	} else if event.DeprecatedCount > 0 {
+		ev.Count = event.DeprecatedCount
+		if !event.DeprecatedLastTimestamp.IsZero() {
+			ev.LastTime = event.DeprecatedLastTimestamp.Time
+		}
+	}
+	return ev
+}
+
+// RegardingKey 按关联对象索引事件的key，kind不区分大小写
+func RegardingKey(kind, namespace, name string) string {
+	return strings.ToLower(kind) + "/" + namespace + "/" + name
+}
+
+func (f *Filter) Match(ev *Event) bool {
+	if len(f.Namespace) != 0 && f.Namespace != ev.Namespace {
+		return false
+	}
+	if len(f.Type) != 0 && !strings.EqualFold(f.Type, ev.Type) {
+		return false
+	}
+	if len(f.Reason) != 0 && f.Reason != ev.Reason {
+		return false
+	}
+	if len(f.Kind) != 0 && !strings.EqualFold(f.Kind, ev.Kind) {
+		return false
+	}
+	if len(f.Name) != 0 && f.Name != ev.ObjectName {
+		return false
+	}
+	if !f.Since.IsZero() && ev.LastTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !ev.LastTime.Before(f.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// DefaultFieldManager server-side apply默认使用的field manager
+const DefaultFieldManager = "easy-k8s"
+
+// apply结果
+const (
+	OperationCreated    = "created"
+	OperationConfigured = "configured"
+	OperationUnchanged  = "unchanged"
+)
+
+// Applier 通过server-side apply应用任意对象，GVR由基于discovery的REST mapper解析
+type Applier struct {
+	dynamicClient dynamic.Interface
+	mapper        *restmapper.DeferredDiscoveryRESTMapper
+	shortcut      meta.RESTMapper
+	fieldManager  string
+}
+
+type Options struct {
+	DryRun bool
+	// Force 强制接管与其他field manager冲突的字段
+	Force bool
+}
+
+// Conflict 与其他field manager冲突的字段
+type Conflict struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type Result struct {
+	Operation string                     `json:"operation"`
+	Object    *unstructured.Unstructured `json:"-"`
+	Live      *unstructured.Unstructured `json:"-"`
+}
+
+func NewApplier(dynamicClient dynamic.Interface, discoveryClient discovery.CachedDiscoveryInterface, fieldManager string) *Applier {
+	if len(fieldManager) == 0 {
+		fieldManager = DefaultFieldManager
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(discoveryClient)
+	return &Applier{
+		dynamicClient: dynamicClient,
+		mapper:        mapper,
+		shortcut:      restmapper.NewShortcutExpander(mapper, discoveryClient, nil),
+		fieldManager:  fieldManager,
+	}
+}
+
+func (a *Applier) FieldManager() string {
+	return a.fieldManager
+}
+
+// Mapping 解析GVK对应的资源，找不到时刷新discovery缓存重试一次，以支持新安装的CRD
+func (a *Applier) Mapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		a.mapper.Reset()
+		mapping, err = a.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// ResourceMapping 解析GVR对应的资源
+func (a *Applier) ResourceMapping(gvr schema.GroupVersionResource) (*meta.RESTMapping, error) {
+	gvk, err := a.mapper.KindFor(gvr)
+	if meta.IsNoMatchError(err) {
+		a.mapper.Reset()
+		gvk, err = a.mapper.KindFor(gvr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.Mapping(gvk)
+}
+
+// NameMapping 解析kubectl风格的资源名称，支持单复数、简称和 resource.group 形式，如 deploy、pods、jobs.batch
+func (a *Applier) NameMapping(name string) (*meta.RESTMapping, error) {
+	gr := schema.ParseGroupResource(strings.ToLower(name))
+	gvr, err := a.shortcut.ResourceFor(gr.WithVersion(""))
+	if meta.IsNoMatchError(err) {
+		a.mapper.Reset()
+		gvr, err = a.shortcut.ResourceFor(gr.WithVersion(""))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.ResourceMapping(gvr)
+}
+
+// ResourceInterface 返回对象所在的资源客户端，集群级别的资源忽略namespace
+func (a *Applier) ResourceInterface(mapping *meta.RESTMapping, namespace string) dynamic.ResourceInterface {
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return a.dynamicClient.Resource(mapping.Resource).Namespace(namespace)
+	}
+	return a.dynamicClient.Resource(mapping.Resource)
+}
+
+// Live 获取对象当前在集群中的状态，不存在时返回nil
+func (a *Applier) Live(ctx context.Context, mapping *meta.RESTMapping, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	live, err := a.ResourceInterface(mapping, obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+// Prepare 解析对象的资源，namespace级别的对象未指定namespace时使用default
+func (a *Applier) Prepare(obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	if len(obj.GetName()) == 0 {
+		return nil, errors.New("metadata.name is required")
+	}
+	mapping, err := a.Mapping(obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && len(obj.GetNamespace()) == 0 {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	return mapping, nil
+}
+
+// Apply 以server-side apply方式应用对象，对象中的managedFields会被清除
+func (a *Applier) Apply(ctx context.Context, obj *unstructured.Unstructured, opts Options) (*Result, error) {
+	mapping, err := a.Prepare(obj)
+	if err != nil {
+		return nil, err
+	}
+	live, err := a.Live(ctx, mapping, obj)
+	if err != nil {
+		return nil, err
+	}
+	obj.SetManagedFields(nil)
+
+	options := metav1.ApplyOptions{FieldManager: a.fieldManager, Force: opts.Force}
+	if opts.DryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	applied, err := a.ResourceInterface(mapping, obj.GetNamespace()).Apply(ctx, obj.GetName(), obj, options)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Operation: OperationConfigured, Object: applied, Live: live}
+	if live == nil {
+		result.Operation = OperationCreated
+	} else if equality.Semantic.DeepEqual(Normalize(live).Object, Normalize(applied).Object) {
+		result.Operation = OperationUnchanged
+	}
+	return result, nil
+}
+
+// Conflicts 从apply返回的409错误中解析冲突的字段
+func Conflicts(err error) []*Conflict {
+	if !apierrors.IsConflict(err) {
+		return nil
+	}
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return nil
+	}
+	var conflicts []*Conflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflicts = append(conflicts, &Conflict{Field: cause.Field, Message: cause.Message})
+	}
+	return conflicts
+}
+
+// Normalize 返回去掉managedFields、resourceVersion等服务端维护字段的副本，用于展示和比较
+func Normalize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	if obj == nil {
+		return nil
+	}
+	obj = obj.DeepCopy()
+	obj.SetManagedFields(nil)
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	return obj
+}
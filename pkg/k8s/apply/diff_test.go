@@ -0,0 +1,71 @@
+package apply
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func configMap(data map[string]any) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]any{"name": "app", "namespace": "default"},
+		"data":       data,
+	}}
+}
+
+func TestDiff(t *testing.T) {
+	live := configMap(map[string]any{"a": "1", "b": "2", "c": "3"})
+	desired := configMap(map[string]any{"a": "1", "b": "20", "c": "3"})
+
+	diff, err := Diff(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `--- live
++++ desired
+@@ -1,7 +1,7 @@
+ apiVersion: v1
+ data:
+   a: "1"
+-  b: "2"
++  b: "20"
+   c: "3"
+ kind: ConfigMap
+ metadata:
+`
+	if diff != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff, want)
+	}
+
+	if diff, _ = Diff(live, live.DeepCopy()); len(diff) != 0 {
+		t.Errorf("diff of equal objects = %q, want empty", diff)
+	}
+}
+
+func TestDiffTooLarge(t *testing.T) {
+	live, desired := map[string]any{}, map[string]any{}
+	for i := 0; i < 2000; i++ {
+		live[fmt.Sprintf("key%04d", i)] = "old"
+		desired[fmt.Sprintf("key%04d", i)] = "new"
+	}
+
+	diff, err := Diff(configMap(live), configMap(desired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != DiffOmitted {
+		t.Errorf("diff = %q, want DiffOmitted", diff[:min(len(diff), 100)])
+	}
+
+	// 只有少量行变化时即使对象很大也计算逐行差异
+	desired = configMap(live).DeepCopy().Object["data"].(map[string]any)
+	desired["key1000"] = "new"
+	diff, _ = Diff(configMap(live), configMap(desired))
+	if !strings.Contains(diff, "-  key1000: old\n+  key1000: new\n") {
+		t.Errorf("diff = %q, want a line diff", diff)
+	}
+}
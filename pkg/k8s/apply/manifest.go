@@ -0,0 +1,78 @@
+package apply
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// 应用顺序：先Namespace，再CRD，最后其他对象
+const (
+	orderNamespace = iota
+	orderCRD
+	orderOther
+)
+
+// ManifestObject 多文档YAML中的一个对象，Index为在原文件中的序号
+type ManifestObject struct {
+	Index  int
+	Object *unstructured.Unstructured
+}
+
+// ParseManifest 解析多文档YAML或JSON，跳过空文档，List类型会被展开
+func ParseManifest(data []byte) ([]*ManifestObject, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objs []*ManifestObject
+	for doc := 0; ; doc++ {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if len(obj.GetAPIVersion()) == 0 || len(obj.GetKind()) == 0 {
+			return nil, fmt.Errorf("document %d: apiVersion and kind are required", doc)
+		}
+		if !obj.IsList() {
+			objs = append(objs, &ManifestObject{Index: len(objs), Object: obj})
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			objs = append(objs, &ManifestObject{Index: len(objs), Object: item.(*unstructured.Unstructured)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", doc, err)
+		}
+	}
+	return objs, nil
+}
+
+// SortByDependency 按Namespace、CRD、其他对象的顺序稳定排序
+func SortByDependency(objs []*ManifestObject) {
+	sort.SliceStable(objs, func(i, j int) bool {
+		return applyOrder(objs[i].Object) < applyOrder(objs[j].Object)
+	})
+}
+
+func applyOrder(obj *unstructured.Unstructured) int {
+	gvk := obj.GroupVersionKind()
+	switch {
+	case gvk.Group == "" && gvk.Kind == "Namespace":
+		return orderNamespace
+	case gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition":
+		return orderCRD
+	default:
+		return orderOther
+	}
+}
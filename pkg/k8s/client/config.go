@@ -3,8 +3,11 @@ package client
 import (
 	"errors"
 
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
@@ -24,3 +27,16 @@ func NewClientset(config *rest.Config) (*kubernetes.Clientset, error) {
 func NewDynamicClient(config *rest.Config) (*dynamic.DynamicClient, error) {
 	return dynamic.NewForConfig(config)
 }
+
+func NewMetadataClient(config *rest.Config) (metadata.Interface, error) {
+	return metadata.NewForConfig(config)
+}
+
+// NewCachedDiscoveryClient 带内存缓存的discovery客户端，CRD变更后需要调用Invalidate刷新
+func NewCachedDiscoveryClient(config *rest.Config) (discovery.CachedDiscoveryInterface, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(discoveryClient), nil
+}
@@ -0,0 +1,123 @@
+package informerfactory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// dynamic/metadata informer 和 pod informer 共用的indexer
+const (
+	OwnerUIDIndex = "ownerUidIdx"
+)
+
+// ForResource 的GVR来自用户请求，最多同时运行的informer数量
+const maxDynamicInformers = 20
+
+// InformerStatus informer的启动和缓存同步状态
+type InformerStatus struct {
+	Name                    string `json:"name"`
+	Started                 bool   `json:"started"`
+	Synced                  bool   `json:"synced"`
+	LastSyncResourceVersion string `json:"lastSyncResourceVersion"`
+}
+
+// ForResource 返回任意GVR的dynamic informer，缓存中的对象为 *unstructured.Unstructured，
+// 超过maxDynamicInformers时停止最久未使用的informer，调用方不应长期持有返回的informer
+func (f *InformerFactory) ForResource(gvr schema.GroupVersionResource) cache.SharedIndexInformer {
+	key := "dynamic/" + gvr.String()
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, exists := f.informers[key]; !exists && len(f.lastUsed) >= maxDynamicInformers {
+		oldest := ""
+		for name, used := range f.lastUsed {
+			if len(oldest) == 0 || used.Before(f.lastUsed[oldest]) {
+				oldest = name
+			}
+		}
+		f.removeInformer(oldest)
+	}
+	f.lastUsed[key] = time.Now()
+
+	return f.getInformerLocked(key, func() cache.SharedIndexInformer {
+		resource := f.dynamicClient.Resource(gvr)
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return resource.List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return resource.Watch(context.Background(), options)
+			},
+		}
+		return cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, f.defaultResync, genericIndexers())
+	})
+}
+
+// ForResourceMetadata 返回任意GVR的metadata informer，只缓存 *metav1.PartialObjectMetadata，
+// 适合只关心label、ownerReferences等元数据的大量对象
+func (f *InformerFactory) ForResourceMetadata(gvr schema.GroupVersionResource) cache.SharedIndexInformer {
+	return f.getInformer("metadata/"+gvr.String(), func() cache.SharedIndexInformer {
+		resource := f.metadataClient.Resource(gvr)
+		lw := &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return resource.List(context.Background(), options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return resource.Watch(context.Background(), options)
+			},
+		}
+		return cache.NewSharedIndexInformer(lw, &metav1.PartialObjectMetadata{}, f.defaultResync, genericIndexers())
+	})
+}
+
+// SyncStatus 返回所有informer的状态，按名称排序
+func (f *InformerFactory) SyncStatus() []*InformerStatus {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	status := make([]*InformerStatus, 0, len(f.informers))
+	for name, informer := range f.informers {
+		_, started := f.cancels[name]
+		status = append(status, &InformerStatus{
+			Name:                    name,
+			Started:                 started,
+			Synced:                  informer.HasSynced(),
+			LastSyncResourceVersion: informer.LastSyncResourceVersion(),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Name < status[j].Name
+	})
+	return status
+}
+
+func genericIndexers() cache.Indexers {
+	return cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		OwnerUIDIndex:        ownerUIDIndexFunc,
+	}
+}
+
+// ownerUIDIndexFunc 以ownerReferences中的uid索引对象，用于查找子对象
+func ownerUIDIndexFunc(obj any) ([]string, error) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	refs := accessor.GetOwnerReferences()
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, string(ref.UID))
+	}
+	return keys, nil
+}
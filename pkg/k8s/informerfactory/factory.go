@@ -8,25 +8,62 @@ import (
 
 	"github.com/go-logr/logr"
 	k8sv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/events"
+	"easy-k8s/pkg/k8s/reference"
+)
+
+// pod informer indexer
+const (
+	NodeNameIndex  = "nodeNameIdx"
+	ConfigMapIndex = "configMapIdx"
+	SecretIndex    = "secretIdx"
+	PVCIndex       = "pvcIdx"
+)
+
+// endpointSlice informer indexer
+const (
+	ServiceNameIndex = "serviceNameIdx"
+)
+
+// event informer indexer
+const (
+	RegardingIndex = "regardingIdx"
+	ReasonIndex    = "reasonIdx"
 )
 
 type newSharedInformer func() cache.SharedIndexInformer
 
 type InformerFactory struct {
-	log           logr.Logger
-	informers     map[string]cache.SharedIndexInformer
-	clientSet     *kubernetes.Clientset
-	lock          sync.Mutex
-	k8sConfig     *rest.Config
-	defaultResync time.Duration
+	log            logr.Logger
+	informers      map[string]cache.SharedIndexInformer
+	clientSet      *kubernetes.Clientset
+	dynamicClient  dynamic.Interface
+	metadataClient metadata.Interface
+	lock           sync.Mutex
+	k8sConfig      *rest.Config
+	defaultResync  time.Duration
+	// Start之后创建的informer立即启动，stopCh关闭时一起停止
+	started bool
+	stopCh  <-chan struct{}
+	// 已启动的informer，可以通过cancel单独停止
+	cancels map[string]context.CancelFunc
+	// ForResource创建的informer最近一次使用的时间，超过maxDynamicInformers时淘汰最久未使用的
+	lastUsed map[string]time.Time
 }
 
 func NewInformerFactory(log logr.Logger, k8sConfig *rest.Config) (*InformerFactory, error) {
@@ -35,6 +72,8 @@ func NewInformerFactory(log logr.Logger, k8sConfig *rest.Config) (*InformerFacto
 		k8sConfig:     k8sConfig,
 		defaultResync: time.Hour,
 		informers:     make(map[string]cache.SharedIndexInformer),
+		cancels:       make(map[string]context.CancelFunc),
+		lastUsed:      make(map[string]time.Time),
 	}
 	clientSet, err := kubernetes.NewForConfig(factory.k8sConfig)
 	if err != nil {
@@ -42,6 +81,17 @@ func NewInformerFactory(log logr.Logger, k8sConfig *rest.Config) (*InformerFacto
 		return nil, err
 	}
 	factory.clientSet = clientSet
+
+	factory.dynamicClient, err = dynamic.NewForConfig(factory.k8sConfig)
+	if err != nil {
+		log.Error(err, "Failed to create dynamic client")
+		return nil, err
+	}
+	factory.metadataClient, err = metadata.NewForConfig(factory.k8sConfig)
+	if err != nil {
+		log.Error(err, "Failed to create metadata client")
+		return nil, err
+	}
 	return factory, nil
 }
 
@@ -50,12 +100,42 @@ func (f *InformerFactory) Start(stopCh <-chan struct{}) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	f.started = true
+	f.stopCh = stopCh
 	for name, informer := range f.informers {
-		f.log.Info("STARTING informer", "name", name)
-		go informer.Run(stopCh)
+		if _, ok := f.cancels[name]; ok {
+			continue
+		}
+		f.runInformer(name, informer)
 	}
 }
 
+// runInformer 在调用方持有锁时运行informer，informer在stopCh关闭或被cancel时停止
+func (f *InformerFactory) runInformer(name string, informer cache.SharedIndexInformer) {
+	f.log.Info("STARTING informer", "name", name)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-f.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go informer.Run(ctx.Done())
+	f.cancels[name] = cancel
+}
+
+// removeInformer 在调用方持有锁时停止并移除informer
+func (f *InformerFactory) removeInformer(name string) {
+	f.log.Info("STOPPING informer", "name", name)
+	if cancel, ok := f.cancels[name]; ok {
+		cancel()
+	}
+	delete(f.informers, name)
+	delete(f.cancels, name)
+	delete(f.lastUsed, name)
+}
+
 // WaitForCacheSync 同步所有Informer的缓存数据
 func (f *InformerFactory) WaitForCacheSync(stopCh <-chan struct{}) {
 	var syncs []cache.InformerSynced
@@ -81,13 +161,144 @@ func (f *InformerFactory) Pod() cache.SharedIndexInformer {
 	return f.getInformer("podInformer", func() cache.SharedIndexInformer {
 		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "pods", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
 		return cache.NewSharedIndexInformer(lw, &k8sv1.Pod{}, f.defaultResync, cache.Indexers{
-			"nodeNameIdx": func(obj any) ([]string, error) {
+			NodeNameIndex: func(obj any) ([]string, error) {
 				pod, ok := obj.(*k8sv1.Pod)
 				if !ok {
 					return nil, fmt.Errorf("unexpected type %T", obj)
 				}
 				return []string{pod.Spec.NodeName}, nil
 			},
+			ConfigMapIndex:       podReferenceIndexFunc(reference.KindConfigMap),
+			SecretIndex:          podReferenceIndexFunc(reference.KindSecret),
+			PVCIndex:             podReferenceIndexFunc(reference.KindPersistentVolumeClaim),
+			OwnerUIDIndex:        ownerUIDIndexFunc,
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		})
+	})
+}
+
+func (f *InformerFactory) Namespace() cache.SharedIndexInformer {
+	return f.getInformer("namespaceInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "namespaces", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.Namespace{}, f.defaultResync, cache.Indexers{})
+	})
+}
+
+func (f *InformerFactory) ConfigMap() cache.SharedIndexInformer {
+	return f.getInformer("configMapInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "configmaps", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.ConfigMap{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) Secret() cache.SharedIndexInformer {
+	return f.getInformer("secretInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "secrets", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.Secret{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) PersistentVolumeClaim() cache.SharedIndexInformer {
+	return f.getInformer("persistentVolumeClaimInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "persistentvolumeclaims", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.PersistentVolumeClaim{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) PersistentVolume() cache.SharedIndexInformer {
+	return f.getInformer("persistentVolumeInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "persistentvolumes", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.PersistentVolume{}, f.defaultResync, cache.Indexers{})
+	})
+}
+
+func (f *InformerFactory) StorageClass() cache.SharedIndexInformer {
+	return f.getInformer("storageClassInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.StorageV1().RESTClient(), "storageclasses", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &storagev1.StorageClass{}, f.defaultResync, cache.Indexers{})
+	})
+}
+
+func (f *InformerFactory) ServiceAccount() cache.SharedIndexInformer {
+	return f.getInformer("serviceAccountInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "serviceaccounts", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.ServiceAccount{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) Service() cache.SharedIndexInformer {
+	return f.getInformer("serviceInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "services", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.Service{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) EndpointSlice() cache.SharedIndexInformer {
+	return f.getInformer("endpointSliceInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.DiscoveryV1().RESTClient(), "endpointslices", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &discoveryv1.EndpointSlice{}, f.defaultResync, cache.Indexers{
+			ServiceNameIndex: func(obj any) ([]string, error) {
+				slice, ok := obj.(*discoveryv1.EndpointSlice)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T", obj)
+				}
+				name, ok := slice.Labels[discoveryv1.LabelServiceName]
+				if !ok {
+					return nil, nil
+				}
+				return []string{slice.Namespace + "/" + name}, nil
+			},
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		})
+	})
+}
+
+func (f *InformerFactory) Ingress() cache.SharedIndexInformer {
+	return f.getInformer("ingressInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.NetworkingV1().RESTClient(), "ingresses", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &networkingv1.Ingress{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) NetworkPolicy() cache.SharedIndexInformer {
+	return f.getInformer("networkPolicyInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.NetworkingV1().RESTClient(), "networkpolicies", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &networkingv1.NetworkPolicy{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) ResourceQuota() cache.SharedIndexInformer {
+	return f.getInformer("resourceQuotaInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "resourcequotas", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.ResourceQuota{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) LimitRange() cache.SharedIndexInformer {
+	return f.getInformer("limitRangeInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.CoreV1().RESTClient(), "limitranges", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &k8sv1.LimitRange{}, f.defaultResync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	})
+}
+
+func (f *InformerFactory) Event() cache.SharedIndexInformer {
+	return f.getInformer("eventInformer", func() cache.SharedIndexInformer {
+		lw := f.newListWatchFromClient(f.clientSet.EventsV1().RESTClient(), "events", k8sv1.NamespaceAll, fields.Everything(), labels.Everything())
+		return cache.NewSharedIndexInformer(lw, &eventsv1.Event{}, f.defaultResync, cache.Indexers{
+			RegardingIndex: func(obj any) ([]string, error) {
+				event, ok := obj.(*eventsv1.Event)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T", obj)
+				}
+				return []string{events.RegardingKey(event.Regarding.Kind, event.Regarding.Namespace, event.Regarding.Name)}, nil
+			},
+			ReasonIndex: func(obj any) ([]string, error) {
+				event, ok := obj.(*eventsv1.Event)
+				if !ok {
+					return nil, fmt.Errorf("unexpected type %T", obj)
+				}
+				return []string{event.Reason}, nil
+			},
 			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
 		})
 	})
@@ -97,6 +308,10 @@ func (f *InformerFactory) getInformer(key string, newFunc newSharedInformer) cac
 	f.lock.Lock()
 	defer f.lock.Unlock()
 
+	return f.getInformerLocked(key, newFunc)
+}
+
+func (f *InformerFactory) getInformerLocked(key string, newFunc newSharedInformer) cache.SharedIndexInformer {
 	informer, exists := f.informers[key]
 	if exists {
 		return informer
@@ -104,9 +319,30 @@ func (f *InformerFactory) getInformer(key string, newFunc newSharedInformer) cac
 	informer = newFunc()
 	f.informers[key] = informer
 
+	// factory已经启动时立即运行新建的informer
+	if f.started {
+		f.runInformer(key, informer)
+	}
+
 	return informer
 }
 
+// podReferenceIndexFunc 以 namespace/name 为key索引pod引用的ConfigMap、Secret或PVC
+func podReferenceIndexFunc(kind string) cache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		pod, ok := obj.(*k8sv1.Pod)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T", obj)
+		}
+		names := reference.PodReferenceNames(pod, kind)
+		keys := make([]string, 0, len(names))
+		for _, name := range names {
+			keys = append(keys, pod.Namespace+"/"+name)
+		}
+		return keys, nil
+	}
+}
+
 func (f *InformerFactory) newListWatchFromClient(c cache.Getter, resource string, namespace string, fieldSelector fields.Selector, labelSelector labels.Selector) *cache.ListWatch {
 	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
 		options.FieldSelector = fieldSelector.String()
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// FakeProvider 本地调试和测试使用的Provider，Err不为空时所有方法都返回该错误
+type FakeProvider struct {
+	Nodes map[string]v1.ResourceList
+	// Pods namespace -> pod name -> usage
+	Pods map[string]map[string]v1.ResourceList
+	Err  error
+}
+
+func (f *FakeProvider) NodeMetrics(_ context.Context) (map[string]v1.ResourceList, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return f.Nodes, nil
+}
+
+func (f *FakeProvider) PodMetrics(_ context.Context, namespace string) (map[string]v1.ResourceList, error) {
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	return f.Pods[namespace], nil
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+
+	"easy-k8s/pkg/comm"
+)
+
+// ErrMetricsUnavailable 集群中没有部署metrics-server或metrics.k8s.io不可用
+var ErrMetricsUnavailable = errors.New("metrics api not available")
+
+// Provider 获取节点和pod的实时cpu/memory用量
+type Provider interface {
+	// NodeMetrics 返回节点名到用量的映射
+	NodeMetrics(ctx context.Context) (map[string]v1.ResourceList, error)
+	// PodMetrics 返回namespace下pod名到用量的映射，用量为所有容器之和
+	PodMetrics(ctx context.Context, namespace string) (map[string]v1.ResourceList, error)
+}
+
+// metrics.k8s.io/v1beta1 中用到的字段，避免引入k8s.io/metrics依赖
+type nodeMetricsList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Usage             v1.ResourceList `json:"usage"`
+	} `json:"items"`
+}
+
+type podMetricsList struct {
+	Items []struct {
+		metav1.ObjectMeta `json:"metadata"`
+		Containers        []struct {
+			Name  string          `json:"name"`
+			Usage v1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+type dynamicProvider struct {
+	client dynamic.Interface
+}
+
+func NewProvider(client dynamic.Interface) Provider {
+	return &dynamicProvider{client: client}
+}
+
+func (p *dynamicProvider) NodeMetrics(ctx context.Context) (map[string]v1.ResourceList, error) {
+	list, err := p.client.Resource(comm.NodeMetricsGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	var metrics nodeMetricsList
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.UnstructuredContent(), &metrics); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]v1.ResourceList, len(metrics.Items))
+	for _, item := range metrics.Items {
+		data[item.Name] = item.Usage
+	}
+	return data, nil
+}
+
+func (p *dynamicProvider) PodMetrics(ctx context.Context, namespace string) (map[string]v1.ResourceList, error) {
+	list, err := p.client.Resource(comm.PodMetricsGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	var metrics podMetricsList
+	if err = runtime.DefaultUnstructuredConverter.FromUnstructured(list.UnstructuredContent(), &metrics); err != nil {
+		return nil, err
+	}
+
+	data := make(map[string]v1.ResourceList, len(metrics.Items))
+	for _, item := range metrics.Items {
+		usage := v1.ResourceList{}
+		for _, container := range item.Containers {
+			for name, quantity := range container.Usage {
+				value := usage[name]
+				value.Add(quantity)
+				usage[name] = value
+			}
+		}
+		data[item.Name] = usage
+	}
+	return data, nil
+}
+
+func convertErr(err error) error {
+	if apierrors.IsNotFound(err) || apierrors.IsServiceUnavailable(err) || meta.IsNoMatchError(err) {
+		return errors.Join(ErrMetricsUnavailable, err)
+	}
+	return err
+}
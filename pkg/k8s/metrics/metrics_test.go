@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"easy-k8s/pkg/comm"
+)
+
+func podMetrics(namespace, name string, containers ...map[string]any) *unstructured.Unstructured {
+	items := make([]any, 0, len(containers))
+	for _, usage := range containers {
+		items = append(items, map[string]any{"name": "c", "usage": usage})
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetrics",
+		"metadata":   map[string]any{"namespace": namespace, "name": name},
+		"containers": items,
+	}}
+}
+
+// newFakeDynamic metrics资源的复数形式无法从kind推断，需按gvr写入tracker
+func newFakeDynamic(t *testing.T, objs ...*unstructured.Unstructured) *dynamicfake.FakeDynamicClient {
+	t.Helper()
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		comm.NodeMetricsGVR: "NodeMetricsList",
+		comm.PodMetricsGVR:  "PodMetricsList",
+	})
+	for _, obj := range objs {
+		gvr := comm.NodeMetricsGVR
+		if obj.GetKind() == "PodMetrics" {
+			gvr = comm.PodMetricsGVR
+		}
+		if err := client.Tracker().Create(gvr, obj, obj.GetNamespace()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return client
+}
+
+func TestPodMetricsSumsContainers(t *testing.T) {
+	client := newFakeDynamic(t,
+		podMetrics("default", "web",
+			map[string]any{"cpu": "100m", "memory": "64Mi"},
+			map[string]any{"cpu": "150m", "memory": "64Mi"},
+		),
+		podMetrics("other", "web", map[string]any{"cpu": "1", "memory": "1Gi"}),
+	)
+
+	data, err := NewProvider(client).PodMetrics(context.Background(), "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("got %d pods, want 1", len(data))
+	}
+	usage := data["web"]
+	if cpu := usage[v1.ResourceCPU]; cpu.Cmp(resource.MustParse("250m")) != 0 {
+		t.Errorf("cpu = %s, want 250m", cpu.String())
+	}
+	if memory := usage[v1.ResourceMemory]; memory.Cmp(resource.MustParse("128Mi")) != 0 {
+		t.Errorf("memory = %s, want 128Mi", memory.String())
+	}
+}
+
+func TestNodeMetrics(t *testing.T) {
+	client := newFakeDynamic(t, &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "NodeMetrics",
+		"metadata":   map[string]any{"name": "node-1"},
+		"usage":      map[string]any{"cpu": "2", "memory": "4Gi"},
+	}})
+
+	data, err := NewProvider(client).NodeMetrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu := data["node-1"][v1.ResourceCPU]; cpu.Cmp(resource.MustParse("2")) != 0 {
+		t.Errorf("cpu = %s, want 2", cpu.String())
+	}
+}
+
+func TestMetricsUnavailable(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		unavailable bool
+	}{
+		{"not found", apierrors.NewNotFound(schema.GroupResource{Group: "metrics.k8s.io", Resource: "nodes"}, ""), true},
+		{"service unavailable", apierrors.NewServiceUnavailable("metrics-server is down"), true},
+		{"forbidden", apierrors.NewForbidden(schema.GroupResource{Group: "metrics.k8s.io", Resource: "nodes"}, "", errors.New("rbac")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeDynamic(t)
+			client.PrependReactor("list", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, tt.err
+			})
+			provider := NewProvider(client)
+
+			_, err := provider.NodeMetrics(context.Background())
+			if errors.Is(err, ErrMetricsUnavailable) != tt.unavailable {
+				t.Errorf("NodeMetrics err = %v, unavailable want %t", err, tt.unavailable)
+			}
+			_, err = provider.PodMetrics(context.Background(), "default")
+			if errors.Is(err, ErrMetricsUnavailable) != tt.unavailable {
+				t.Errorf("PodMetrics err = %v, unavailable want %t", err, tt.unavailable)
+			}
+		})
+	}
+}
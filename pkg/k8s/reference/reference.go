@@ -0,0 +1,158 @@
+package reference
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	KindConfigMap             = "ConfigMap"
+	KindSecret                = "Secret"
+	KindPersistentVolumeClaim = "PersistentVolumeClaim"
+	KindServiceAccount        = "ServiceAccount"
+)
+
+// reference source
+const (
+	SourceVolume          = "volume"
+	SourceEnv             = "env"
+	SourceEnvFrom         = "envFrom"
+	SourceImagePullSecret = "imagePullSecret"
+	SourceServiceAccount  = "serviceAccount"
+)
+
+// Reference pod对其他对象的一次引用
+type Reference struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Optional bool   `json:"optional"`
+	Source   string `json:"source"`
+	// Container 引用所在的容器，volume/imagePullSecret等pod级别的引用为空
+	Container string `json:"container,omitempty"`
+	// Detail volume名称或环境变量名称
+	Detail string `json:"detail,omitempty"`
+	// Key env中引用的ConfigMap/Secret的key
+	Key string `json:"key,omitempty"`
+}
+
+// PodReferences 返回pod通过volume、env、envFrom、imagePullSecrets、serviceAccount引用的所有对象
+func PodReferences(pod *v1.Pod) []*Reference {
+	var refs []*Reference
+	for _, volume := range pod.Spec.Volumes {
+		refs = append(refs, volumeReferences(pod, volume)...)
+	}
+
+	containers := make([]v1.Container, 0, len(pod.Spec.InitContainers)+len(pod.Spec.Containers)+len(pod.Spec.EphemeralContainers))
+	containers = append(containers, pod.Spec.InitContainers...)
+	containers = append(containers, pod.Spec.Containers...)
+	for _, container := range pod.Spec.EphemeralContainers {
+		containers = append(containers, v1.Container(container.EphemeralContainerCommon))
+	}
+	for _, container := range containers {
+		refs = append(refs, containerReferences(container)...)
+	}
+
+	// kubelet会忽略不存在的imagePullSecret，只有镜像仓库需要认证时拉取才会失败，因此视为optional
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		refs = append(refs, &Reference{Kind: KindSecret, Name: secret.Name, Optional: true, Source: SourceImagePullSecret})
+	}
+
+	serviceAccount := pod.Spec.ServiceAccountName
+	if len(serviceAccount) == 0 {
+		serviceAccount = "default"
+	}
+	refs = append(refs, &Reference{Kind: KindServiceAccount, Name: serviceAccount, Source: SourceServiceAccount})
+	return refs
+}
+
+// PodReferenceNames 返回pod引用的某类对象的名称，已去重
+func PodReferenceNames(pod *v1.Pod, kind string) []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, ref := range PodReferences(pod) {
+		if ref.Kind != kind {
+			continue
+		}
+		if _, ok := seen[ref.Name]; ok {
+			continue
+		}
+		seen[ref.Name] = struct{}{}
+		names = append(names, ref.Name)
+	}
+	return names
+}
+
+func volumeReferences(pod *v1.Pod, volume v1.Volume) []*Reference {
+	var refs []*Reference
+	switch {
+	case volume.ConfigMap != nil:
+		refs = append(refs, &Reference{Kind: KindConfigMap, Name: volume.ConfigMap.Name, Optional: isOptional(volume.ConfigMap.Optional)})
+	case volume.Secret != nil:
+		refs = append(refs, &Reference{Kind: KindSecret, Name: volume.Secret.SecretName, Optional: isOptional(volume.Secret.Optional)})
+	case volume.PersistentVolumeClaim != nil:
+		refs = append(refs, &Reference{Kind: KindPersistentVolumeClaim, Name: volume.PersistentVolumeClaim.ClaimName})
+	case volume.Ephemeral != nil:
+		refs = append(refs, &Reference{Kind: KindPersistentVolumeClaim, Name: fmt.Sprintf("%s-%s", pod.Name, volume.Name)})
+	case volume.CSI != nil && volume.CSI.NodePublishSecretRef != nil:
+		refs = append(refs, &Reference{Kind: KindSecret, Name: volume.CSI.NodePublishSecretRef.Name})
+	case volume.Projected != nil:
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				refs = append(refs, &Reference{Kind: KindConfigMap, Name: source.ConfigMap.Name, Optional: isOptional(source.ConfigMap.Optional)})
+			}
+			if source.Secret != nil {
+				refs = append(refs, &Reference{Kind: KindSecret, Name: source.Secret.Name, Optional: isOptional(source.Secret.Optional)})
+			}
+		}
+	}
+	for _, ref := range refs {
+		ref.Source = SourceVolume
+		ref.Detail = volume.Name
+	}
+	return refs
+}
+
+func containerReferences(container v1.Container) []*Reference {
+	var refs []*Reference
+	for _, env := range container.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+			refs = append(refs, &Reference{
+				Kind:      KindConfigMap,
+				Name:      ref.Name,
+				Optional:  isOptional(ref.Optional),
+				Source:    SourceEnv,
+				Container: container.Name,
+				Detail:    env.Name,
+				Key:       ref.Key,
+			})
+		}
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+			refs = append(refs, &Reference{
+				Kind:      KindSecret,
+				Name:      ref.Name,
+				Optional:  isOptional(ref.Optional),
+				Source:    SourceEnv,
+				Container: container.Name,
+				Detail:    env.Name,
+				Key:       ref.Key,
+			})
+		}
+	}
+	for _, envFrom := range container.EnvFrom {
+		if ref := envFrom.ConfigMapRef; ref != nil {
+			refs = append(refs, &Reference{Kind: KindConfigMap, Name: ref.Name, Optional: isOptional(ref.Optional), Source: SourceEnvFrom, Container: container.Name})
+		}
+		if ref := envFrom.SecretRef; ref != nil {
+			refs = append(refs, &Reference{Kind: KindSecret, Name: ref.Name, Optional: isOptional(ref.Optional), Source: SourceEnvFrom, Container: container.Name})
+		}
+	}
+	return refs
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
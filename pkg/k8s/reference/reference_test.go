@@ -0,0 +1,35 @@
+package reference
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPodReferencesOptional(t *testing.T) {
+	optional := true
+	pod := &v1.Pod{Spec: v1.PodSpec{
+		Volumes: []v1.Volume{
+			{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "app"}}}},
+			{Name: "certs", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "tls", Optional: &optional}}},
+		},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+	}}
+
+	want := map[string]bool{
+		KindConfigMap + "/app":          false,
+		KindSecret + "/tls":             true,
+		KindSecret + "/registry":        true,
+		KindServiceAccount + "/default": false,
+	}
+	refs := PodReferences(pod)
+	if len(refs) != len(want) {
+		t.Fatalf("got %d references, want %d", len(refs), len(want))
+	}
+	for _, ref := range refs {
+		key := ref.Kind + "/" + ref.Name
+		if wantOptional, ok := want[key]; !ok || ref.Optional != wantOptional {
+			t.Errorf("reference %s optional = %t, want %t", key, ref.Optional, wantOptional)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package nodehistory
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/store"
+)
+
+const (
+	bucketHistory   = "nodeHistory"
+	bucketSnapshots = "nodeSnapshots"
+)
+
+// 变更类型
+const (
+	TypeCreated   = "created"
+	TypeDeleted   = "deleted"
+	TypeStatus    = "status"
+	TypeCondition = "condition"
+	TypeLabel     = "label"
+	TypeTaint     = "taint"
+	TypeCordon    = "cordon"
+)
+
+// Entry 节点的一次变更，同一次更新中的多种变更按类型拆分为多条
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Node    string    `json:"node"`
+	Type    string    `json:"type"`
+	Summary string    `json:"summary"`
+	Changes []*Change `json:"changes,omitempty"`
+}
+
+// 单项变更的动作
+const (
+	ActionAdded   = "added"
+	ActionRemoved = "removed"
+	ActionChanged = "changed"
+)
+
+type Change struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Snapshot 节点最近一次记录的状态，用于重启后与informer首次list到的状态比较
+type Snapshot struct {
+	Labels        map[string]string `json:"labels"`
+	Taints        map[string]string `json:"taints"`
+	Unschedulable bool              `json:"unschedulable"`
+	Conditions    map[string]string `json:"conditions"`
+	// reasons 只用于生成变更说明，不保存
+	reasons map[string]string
+}
+
+// Recorder 通过node informer的回调记录节点的状态、condition、label、taint和cordon变化
+type Recorder struct {
+	log       logr.Logger
+	store     *store.Store
+	retention time.Duration
+}
+
+func NewRecorder(log logr.Logger, store *store.Store, retention time.Duration) *Recorder {
+	return &Recorder{
+		log:       log.WithName("NodeHistory"),
+		store:     store,
+		retention: retention,
+	}
+}
+
+func (r *Recorder) Handler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if node, ok := obj.(*v1.Node); ok {
+				r.onAdd(node)
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldNode, ok1 := oldObj.(*v1.Node)
+			newNode, ok2 := newObj.(*v1.Node)
+			if ok1 && ok2 && oldNode.ResourceVersion != newNode.ResourceVersion {
+				r.record(newNode.Name, newSnapshot(oldNode), newSnapshot(newNode), time.Now())
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if node, ok := obj.(*v1.Node); ok {
+				r.put(&Entry{Time: time.Now(), Node: node.Name, Type: TypeDeleted, Summary: "node deleted"})
+				// 同名节点重新注册时记录为新节点
+				if err := r.store.Delete(bucketSnapshots, []byte(node.Name)); err != nil {
+					r.log.Error(err, "delete node snapshot err", "node", node.Name)
+				}
+			}
+		},
+	}
+}
+
+// onAdd 与上次保存的快照比较，记录easy-k8s停止期间发生的变化；没有快照时记录为新节点
+func (r *Recorder) onAdd(node *v1.Node) {
+	current := newSnapshot(node)
+	previous := &Snapshot{}
+	exists, err := r.store.Get(bucketSnapshots, []byte(node.Name), previous)
+	if err != nil {
+		r.log.Error(err, "get node snapshot err", "node", node.Name)
+		return
+	}
+	if !exists {
+		created := node.CreationTimestamp.Time
+		if created.IsZero() {
+			created = time.Now()
+		}
+		r.put(&Entry{Time: created, Node: node.Name, Type: TypeCreated, Summary: "node registered"})
+		r.saveSnapshot(node.Name, current)
+		return
+	}
+	r.record(node.Name, previous, current, time.Now())
+}
+
+// record 同一次更新拆分出的多条变更在一个事务中写入
+func (r *Recorder) record(name string, old, new *Snapshot, now time.Time) {
+	entries := diffSnapshots(old, new)
+	if len(entries) == 0 {
+		return
+	}
+	puts := make([]store.Entry, 0, len(entries))
+	for _, entry := range entries {
+		entry.Time, entry.Node = now, name
+		puts = append(puts, store.Entry{Key: entryKey(entry), Value: entry})
+	}
+	if err := r.store.Write(bucketHistory, puts, nil); err != nil {
+		r.log.Error(err, "record node history err", "node", name, "entries", len(entries))
+	}
+	r.saveSnapshot(name, new)
+}
+
+func (r *Recorder) put(entry *Entry) {
+	if err := r.store.Put(bucketHistory, entryKey(entry), entry); err != nil {
+		r.log.Error(err, "record node history err", "node", entry.Node, "type", entry.Type)
+	}
+}
+
+// entryKey 以 节点名/时间/类型 为key，查询单个节点时只需要遍历该节点的前缀
+func entryKey(entry *Entry) []byte {
+	return store.TimeKey(nodePrefix(entry.Node), entry.Time, "/"+entry.Type)
+}
+
+func (r *Recorder) saveSnapshot(name string, snapshot *Snapshot) {
+	if err := r.store.Put(bucketSnapshots, []byte(name), snapshot); err != nil {
+		r.log.Error(err, "save node snapshot err", "node", name)
+	}
+}
+
+// Timeline 返回节点在时间窗口内的变更，按时间倒序
+func (r *Recorder) Timeline(node string, since, until time.Time) ([]*Entry, error) {
+	prefix := nodePrefix(node)
+	min, max := []byte(prefix), prefixEnd(prefix)
+	if !since.IsZero() {
+		min = store.TimeKey(prefix, since, "")
+	}
+	if !until.IsZero() {
+		max = store.TimeKey(prefix, until, "")
+	}
+	entries := []*Entry{}
+	err := r.store.Scan(bucketHistory, min, max, func(key, value []byte) error {
+		entry := &Entry{}
+		if err := json.Unmarshal(value, entry); err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.After(entries[j].Time)
+	})
+	return entries, err
+}
+
+// Run 定期清理超过保留时间的记录
+func (r *Recorder) Run(ctx context.Context) {
+	r.log.Info("STARTING node history recorder", "retention", r.retention)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		deleted, err := r.deleteBefore(time.Now().Add(-r.retention))
+		if err != nil {
+			r.log.Error(err, "delete expired node history err")
+		} else if deleted > 0 {
+			r.log.Info("cleaned node history", "deleted", deleted)
+		}
+	}, time.Hour)
+}
+
+// deleteBefore key以节点名开头，过期的记录分散在各个节点的前缀下，需要解析key中的时间
+func (r *Recorder) deleteBefore(cutoff time.Time) (int, error) {
+	var expired [][]byte
+	err := r.store.Scan(bucketHistory, nil, nil, func(key, _ []byte) error {
+		i := bytes.IndexByte(key, '/')
+		if i < 0 || len(key) < i+9 {
+			return nil
+		}
+		if int64(binary.BigEndian.Uint64(key[i+1:i+9])) < cutoff.UnixNano() {
+			expired = append(expired, bytes.Clone(key))
+		}
+		return nil
+	})
+	if err != nil || len(expired) == 0 {
+		return 0, err
+	}
+	return len(expired), r.store.Write(bucketHistory, nil, expired)
+}
+
+// nodePrefix 节点名中不会出现 /
+func nodePrefix(node string) string {
+	return node + "/"
+}
+
+// prefixEnd 大于所有以prefix开头的key的最小值，prefix以 / 结尾，/ 的下一个字符为 0
+func prefixEnd(prefix string) []byte {
+	return []byte(strings.TrimSuffix(prefix, "/") + "0")
+}
+
+func newSnapshot(node *v1.Node) *Snapshot {
+	snapshot := &Snapshot{
+		Labels:        node.Labels,
+		Taints:        make(map[string]string, len(node.Spec.Taints)),
+		Unschedulable: node.Spec.Unschedulable,
+		Conditions:    make(map[string]string, len(node.Status.Conditions)),
+		reasons:       make(map[string]string, len(node.Status.Conditions)),
+	}
+	for _, taint := range node.Spec.Taints {
+		snapshot.Taints[taint.Key+":"+string(taint.Effect)] = taint.Value
+	}
+	for _, condition := range node.Status.Conditions {
+		snapshot.Conditions[string(condition.Type)] = string(condition.Status)
+		snapshot.reasons[string(condition.Type)] = condition.Reason
+	}
+	return snapshot
+}
+
+func diffSnapshots(old, new *Snapshot) []*Entry {
+	var entries []*Entry
+
+	if oldStatus, newStatus := readyStatus(old), readyStatus(new); oldStatus != newStatus {
+		entries = append(entries, &Entry{
+			Type:    TypeStatus,
+			Summary: fmt.Sprintf("%s -> %s", oldStatus, newStatus),
+			Changes: []*Change{{Key: string(v1.NodeReady), Action: ActionChanged, Old: old.Conditions[string(v1.NodeReady)], New: new.Conditions[string(v1.NodeReady)], Reason: new.reasons[string(v1.NodeReady)]}},
+		})
+	}
+	// Ready的变化已经记录为状态变化
+	var conditionChanges []*Change
+	for _, change := range diffMap(old.Conditions, new.Conditions) {
+		if change.Key != string(v1.NodeReady) {
+			change.Reason = new.reasons[change.Key]
+			conditionChanges = append(conditionChanges, change)
+		}
+	}
+	if changes := conditionChanges; len(changes) != 0 {
+		entries = append(entries, &Entry{Type: TypeCondition, Summary: fmt.Sprintf("%d condition(s) changed", len(changes)), Changes: changes})
+	}
+	if changes := diffMap(old.Labels, new.Labels); len(changes) != 0 {
+		entries = append(entries, &Entry{Type: TypeLabel, Summary: fmt.Sprintf("%d label(s) changed", len(changes)), Changes: changes})
+	}
+	if changes := diffMap(old.Taints, new.Taints); len(changes) != 0 {
+		entries = append(entries, &Entry{Type: TypeTaint, Summary: fmt.Sprintf("%d taint(s) changed", len(changes)), Changes: changes})
+	}
+	if old.Unschedulable != new.Unschedulable {
+		summary := "uncordoned"
+		if new.Unschedulable {
+			summary = "cordoned"
+		}
+		entries = append(entries, &Entry{Type: TypeCordon, Summary: summary})
+	}
+	return entries
+}
+
+// readyStatus 与kubectl一致的节点状态
+func readyStatus(snapshot *Snapshot) string {
+	switch v1.ConditionStatus(snapshot.Conditions[string(v1.NodeReady)]) {
+	case v1.ConditionTrue:
+		return "Ready"
+	case v1.ConditionFalse:
+		return "NotReady"
+	default:
+		return "Unknown"
+	}
+}
+
+// diffMap 按key排序返回新增、删除和修改的项
+func diffMap(old, new map[string]string) []*Change {
+	var changes []*Change
+	for key, value := range new {
+		oldValue, ok := old[key]
+		if !ok {
+			changes = append(changes, &Change{Key: key, Action: ActionAdded, New: value})
+		} else if oldValue != value {
+			changes = append(changes, &Change{Key: key, Action: ActionChanged, Old: oldValue, New: value})
+		}
+	}
+	for key, value := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, &Change{Key: key, Action: ActionRemoved, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
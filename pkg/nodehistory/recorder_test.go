@@ -0,0 +1,238 @@
+package nodehistory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"easy-k8s/pkg/store"
+)
+
+func newTestRecorder(t *testing.T) *Recorder {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewRecorder(logr.Discard(), db, time.Hour)
+}
+
+func entryTypes(entries []*Entry) []string {
+	types := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		types = append(types, entry.Type)
+	}
+	return types
+}
+
+func TestTimelineOnlyScansNode(t *testing.T) {
+	r := newTestRecorder(t)
+	now := time.Now()
+	// node-1 是 node-10 的前缀
+	r.put(&Entry{Time: now.Add(-2 * time.Minute), Node: "node-1", Type: TypeLabel})
+	r.put(&Entry{Time: now.Add(-time.Minute), Node: "node-10", Type: TypeTaint})
+	r.put(&Entry{Time: now, Node: "node-1", Type: TypeCordon})
+
+	entries, err := r.Timeline("node-1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryTypes(entries); len(got) != 2 || got[0] != TypeCordon || got[1] != TypeLabel {
+		t.Errorf("timeline = %v, want [cordon label]", got)
+	}
+
+	entries, _ = r.Timeline("node-1", now.Add(-90*time.Second), time.Time{})
+	if got := entryTypes(entries); len(got) != 1 || got[0] != TypeCordon {
+		t.Errorf("timeline since = %v, want [cordon]", got)
+	}
+}
+
+func TestDeleteBefore(t *testing.T) {
+	r := newTestRecorder(t)
+	now := time.Now()
+	r.put(&Entry{Time: now.Add(-2 * time.Hour), Node: "node-1", Type: TypeLabel})
+	r.put(&Entry{Time: now.Add(-2 * time.Hour), Node: "node-2", Type: TypeLabel})
+	r.put(&Entry{Time: now, Node: "node-2", Type: TypeTaint})
+
+	deleted, err := r.deleteBefore(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d entries, want 2", deleted)
+	}
+	entries, _ := r.Timeline("node-2", time.Time{}, time.Time{})
+	if got := entryTypes(entries); len(got) != 1 || got[0] != TypeTaint {
+		t.Errorf("timeline = %v, want [taint]", got)
+	}
+}
+
+func TestReRegisteredNode(t *testing.T) {
+	r := newTestRecorder(t)
+	handler := r.Handler()
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Minute))}}
+
+	handler.OnAdd(node, false)
+	handler.OnDelete(node)
+	node = node.DeepCopy()
+	node.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Second))
+	handler.OnAdd(node, false)
+
+	entries, err := r.Timeline("node-1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := entryTypes(entries); len(got) != 3 || got[0] != TypeCreated || got[1] != TypeDeleted || got[2] != TypeCreated {
+		t.Errorf("timeline = %v, want [created deleted created]", got)
+	}
+}
+
+func historyNode(mutate func(node *v1.Node)) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1", ResourceVersion: "1", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)), Labels: map[string]string{"zone": "a", "pool": "cpu"}},
+		Spec:       v1.NodeSpec{Taints: []v1.Taint{{Key: "dedicated", Value: "infra", Effect: v1.TaintEffectNoSchedule}}},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{
+			{Type: v1.NodeReady, Status: v1.ConditionTrue, Reason: "KubeletReady"},
+			{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse, Reason: "KubeletHasSufficientMemory"},
+		}},
+	}
+	if mutate != nil {
+		mutate(node)
+	}
+	return node
+}
+
+type wantEntry struct {
+	summary string
+	changes []Change
+}
+
+func assertEntries(t *testing.T, entries []*Entry, want map[string]wantEntry) {
+	t.Helper()
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %v, want %d entries", entryTypes(entries), len(want))
+	}
+	for _, entry := range entries {
+		w, ok := want[entry.Type]
+		if !ok {
+			t.Errorf("unexpected %s entry %+v", entry.Type, entry)
+			continue
+		}
+		if entry.Node != "node-1" || entry.Summary != w.summary {
+			t.Errorf("%s entry = %s %q, want node-1 %q", entry.Type, entry.Node, entry.Summary, w.summary)
+		}
+		var changes []Change
+		for _, change := range entry.Changes {
+			changes = append(changes, *change)
+		}
+		if !reflect.DeepEqual(changes, w.changes) {
+			t.Errorf("%s changes = %+v, want %+v", entry.Type, changes, w.changes)
+		}
+	}
+}
+
+func TestRecordUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(node *v1.Node)
+		want   map[string]wantEntry
+	}{
+		{"not ready", func(node *v1.Node) {
+			node.Status.Conditions[0].Status, node.Status.Conditions[0].Reason = v1.ConditionFalse, "KubeletNotReady"
+		}, map[string]wantEntry{
+			TypeStatus: {"Ready -> NotReady", []Change{{Key: "Ready", Action: ActionChanged, Old: "True", New: "False", Reason: "KubeletNotReady"}}},
+		}},
+		{"condition", func(node *v1.Node) {
+			node.Status.Conditions[1].Status, node.Status.Conditions[1].Reason = v1.ConditionTrue, "KubeletHasInsufficientMemory"
+			node.Status.Conditions = append(node.Status.Conditions, v1.NodeCondition{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse, Reason: "KubeletHasNoDiskPressure"})
+		}, map[string]wantEntry{
+			TypeCondition: {"2 condition(s) changed", []Change{
+				{Key: "DiskPressure", Action: ActionAdded, New: "False", Reason: "KubeletHasNoDiskPressure"},
+				{Key: "MemoryPressure", Action: ActionChanged, Old: "False", New: "True", Reason: "KubeletHasInsufficientMemory"},
+			}},
+		}},
+		{"label", func(node *v1.Node) {
+			node.Labels = map[string]string{"zone": "b", "gpu": "true"}
+		}, map[string]wantEntry{
+			TypeLabel: {"3 label(s) changed", []Change{
+				{Key: "gpu", Action: ActionAdded, New: "true"},
+				{Key: "pool", Action: ActionRemoved, Old: "cpu"},
+				{Key: "zone", Action: ActionChanged, Old: "a", New: "b"},
+			}},
+		}},
+		{"taint", func(node *v1.Node) {
+			node.Spec.Taints = []v1.Taint{{Key: "dedicated", Value: "gpu", Effect: v1.TaintEffectNoSchedule}, {Key: "maintenance", Effect: v1.TaintEffectNoExecute}}
+		}, map[string]wantEntry{
+			TypeTaint: {"2 taint(s) changed", []Change{
+				{Key: "dedicated:NoSchedule", Action: ActionChanged, Old: "infra", New: "gpu"},
+				{Key: "maintenance:NoExecute", Action: ActionAdded},
+			}},
+		}},
+		// cordon同时会加上unschedulable污点
+		{"cordon", func(node *v1.Node) {
+			node.Spec.Unschedulable = true
+			node.Spec.Taints = append(node.Spec.Taints, v1.Taint{Key: v1.TaintNodeUnschedulable, Effect: v1.TaintEffectNoSchedule})
+		}, map[string]wantEntry{
+			TypeCordon: {"cordoned", nil},
+			TypeTaint:  {"1 taint(s) changed", []Change{{Key: v1.TaintNodeUnschedulable + ":NoSchedule", Action: ActionAdded}}},
+		}},
+		{"heartbeat only", func(node *v1.Node) {
+			node.Status.Conditions[0].LastHeartbeatTime = metav1.Now()
+		}, map[string]wantEntry{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRecorder(t)
+			newNode := historyNode(tt.mutate)
+			newNode.ResourceVersion = "2"
+			r.Handler().OnUpdate(historyNode(nil), newNode)
+
+			entries, err := r.Timeline("node-1", time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertEntries(t, entries, tt.want)
+		})
+	}
+}
+
+func TestRecordOfflineChanges(t *testing.T) {
+	r := newTestRecorder(t)
+	handler := r.Handler()
+	handler.OnAdd(historyNode(nil), true)
+
+	// 模拟easy-k8s停止期间节点被cordon并修改了label，重启后informer首次list到新状态
+	r = NewRecorder(logr.Discard(), r.store, time.Hour)
+	handler = r.Handler()
+	handler.OnAdd(historyNode(func(node *v1.Node) {
+		node.Spec.Unschedulable = true
+		node.Labels["pool"] = "gpu"
+	}), true)
+
+	entries, err := r.Timeline("node-1", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[len(entries)-1].Type != TypeCreated {
+		t.Fatalf("timeline = %v, want 2 changes after created", entryTypes(entries))
+	}
+	assertEntries(t, entries[:2], map[string]wantEntry{
+		TypeCordon: {"cordoned", nil},
+		TypeLabel:  {"1 label(s) changed", []Change{{Key: "pool", Action: ActionChanged, Old: "cpu", New: "gpu"}}},
+	})
+
+	// 状态未变化时重启不产生记录
+	handler.OnAdd(historyNode(func(node *v1.Node) {
+		node.Spec.Unschedulable = true
+		node.Labels["pool"] = "gpu"
+	}), true)
+	if entries, _ = r.Timeline("node-1", time.Time{}, time.Time{}); len(entries) != 3 {
+		t.Errorf("timeline = %v, want no new entries", entryTypes(entries))
+	}
+}
@@ -0,0 +1,156 @@
+package nstemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultTemplate 未配置模板文件时只给namespace打上团队标签
+const defaultTemplate = `
+labels:
+  osgalaxy.io/team: {{quote .Team}}
+`
+
+// Params 模板中可以使用的参数
+type Params struct {
+	Name string
+	Team string
+}
+
+// Validate Name必须是合法的namespace名称，Team会作为label值使用
+func (p Params) Validate() error {
+	var errs []string
+	for _, msg := range validation.IsDNS1123Label(p.Name) {
+		errs = append(errs, "name: "+msg)
+	}
+	for _, msg := range validation.IsValidLabelValue(p.Team) {
+		errs = append(errs, "team: "+msg)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("invalid params: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// quote 将参数渲染为带引号的yaml字符串，避免参数内容改变模板结构
+func quote(value string) (string, error) {
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// Spec 渲染后的namespace初始化配置，除namespace外的对象都与namespace同名
+type Spec struct {
+	Labels        map[string]string               `json:"labels"`
+	Annotations   map[string]string               `json:"annotations"`
+	ResourceQuota *corev1.ResourceQuotaSpec       `json:"resourceQuota"`
+	LimitRange    *corev1.LimitRangeSpec          `json:"limitRange"`
+	NetworkPolicy *networkingv1.NetworkPolicySpec `json:"networkPolicy"`
+	RoleBindings  []*RoleBinding                  `json:"roleBindings"`
+}
+
+// RoleBinding 将ClusterRole授予团队的用户组
+type RoleBinding struct {
+	Name        string   `json:"name"`
+	ClusterRole string   `json:"clusterRole"`
+	Groups      []string `json:"groups"`
+}
+
+type Template struct {
+	tmpl *template.Template
+}
+
+// Load 加载yaml格式的模板文件，文件中可以使用 {{quote .Name}} {{quote .Team}}，path为空时使用默认模板
+func Load(path string) (*Template, error) {
+	text := defaultTemplate
+	if len(path) != 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("namespace").Funcs(template.FuncMap{"quote": quote}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+func (t *Template) Render(params Params) (*Spec, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, params); err != nil {
+		return nil, err
+	}
+	spec := &Spec{}
+	if err := yaml.UnmarshalStrict(buf.Bytes(), spec); err != nil {
+		return nil, fmt.Errorf("invalid namespace template: %w", err)
+	}
+	return spec, nil
+}
+
+// Objects 按创建顺序返回需要创建的对象，第一个为Namespace
+func (s *Spec) Objects(name string) ([]*unstructured.Unstructured, error) {
+	objs := []runtime.Object{&corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: s.Labels, Annotations: s.Annotations},
+	}}
+	meta := metav1.ObjectMeta{Name: name, Namespace: name}
+	if s.ResourceQuota != nil {
+		objs = append(objs, &corev1.ResourceQuota{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ResourceQuota"},
+			ObjectMeta: meta,
+			Spec:       *s.ResourceQuota,
+		})
+	}
+	if s.LimitRange != nil {
+		objs = append(objs, &corev1.LimitRange{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "LimitRange"},
+			ObjectMeta: meta,
+			Spec:       *s.LimitRange,
+		})
+	}
+	if s.NetworkPolicy != nil {
+		objs = append(objs, &networkingv1.NetworkPolicy{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "networking.k8s.io/v1", Kind: "NetworkPolicy"},
+			ObjectMeta: meta,
+			Spec:       *s.NetworkPolicy,
+		})
+	}
+	for _, binding := range s.RoleBindings {
+		roleBinding := &rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: binding.Name, Namespace: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: binding.ClusterRole},
+		}
+		for _, group := range binding.Groups {
+			roleBinding.Subjects = append(roleBinding.Subjects, rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: group})
+		}
+		objs = append(objs, roleBinding)
+	}
+
+	data := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, &unstructured.Unstructured{Object: content})
+	}
+	return data, nil
+}
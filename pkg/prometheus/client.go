@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client Prometheus HTTP API客户端，只实现了范围查询
+type Client struct {
+	addr       string
+	httpClient *http.Client
+}
+
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values []Point           `json:"values"`
+}
+
+type Point struct {
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+}
+
+type queryRangeRsp struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][2]any          `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+}
+
+func NewClient(addr string, timeout time.Duration) *Client {
+	return &Client{
+		addr:       strings.TrimRight(addr, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// QueryRange 调用 /api/v1/query_range，返回matrix类型的结果
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]*Series, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+"/api/v1/query_range", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rsp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var result queryRangeRsp
+	if err = json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("prometheus response status %d: %w", rsp.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("prometheus query failed: %s: %s", result.ErrorType, result.Error)
+	}
+	if result.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected prometheus result type %s", result.Data.ResultType)
+	}
+
+	data := make([]*Series, 0, len(result.Data.Result))
+	for _, item := range result.Data.Result {
+		series := &Series{Metric: item.Metric, Values: make([]Point, 0, len(item.Values))}
+		for _, value := range item.Values {
+			ts, _ := value[0].(float64)
+			raw, _ := value[1].(string)
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, err
+			}
+			series.Values = append(series.Values, Point{Time: int64(ts), Value: v})
+		}
+		data = append(data, series)
+	}
+	return data, nil
+}
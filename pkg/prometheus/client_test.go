@@ -0,0 +1,126 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestQueryRange(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		for key, want := range map[string]string{"query": "up", "start": "1700000000", "end": "1700000600", "step": "60"} {
+			if got := r.PostForm.Get(key); got != want {
+				t.Errorf("%s = %q, want %q", key, got, want)
+			}
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"instance":"node-1"},"values":[[1700000000,"1"],[1700000060.5,"0.25"]]}
+		]}}`))
+	})
+
+	start := time.Unix(1700000000, 0)
+	data, err := NewClient(server.URL+"/", time.Second).QueryRange(context.Background(), "up", start, start.Add(10*time.Minute), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0].Metric["instance"] != "node-1" {
+		t.Fatalf("series = %+v", data)
+	}
+	want := []Point{{Time: 1700000000, Value: 1}, {Time: 1700000060, Value: 0.25}}
+	if len(data[0].Values) != len(want) {
+		t.Fatalf("values = %+v, want %+v", data[0].Values, want)
+	}
+	for i, point := range data[0].Values {
+		if point != want[i] {
+			t.Errorf("values[%d] = %+v, want %+v", i, point, want[i])
+		}
+	}
+}
+
+func TestQueryRangeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{
+			name:   "query error",
+			status: http.StatusBadRequest,
+			body:   `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+			want:   "bad_data: parse error",
+		},
+		{
+			name:   "not json",
+			status: http.StatusBadGateway,
+			body:   `<html>bad gateway</html>`,
+			want:   "status 502",
+		},
+		{
+			name:   "not matrix",
+			status: http.StatusOK,
+			body:   `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			want:   "result type vector",
+		},
+		{
+			name:   "bad value",
+			status: http.StatusOK,
+			body:   `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{},"values":[[1700000000,"x"]]}]}}`,
+			want:   "invalid syntax",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			now := time.Now()
+			_, err := NewClient(server.URL, time.Second).QueryRange(context.Background(), "up", now.Add(-time.Hour), now, time.Minute)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRangeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+
+	now := time.Now()
+	_, err := NewClient(server.URL, 50*time.Millisecond).QueryRange(context.Background(), "up", now.Add(-time.Hour), now, time.Minute)
+	var netErr interface{ Timeout() bool }
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("err = %v, want timeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = NewClient(server.URL, time.Second).QueryRange(ctx, "up", now.Add(-time.Hour), now, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context canceled", err)
+	}
+}
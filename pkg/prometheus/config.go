@@ -0,0 +1,81 @@
+package prometheus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+type Config struct {
+	Address string          `json:"address"`
+	Timeout metav1.Duration `json:"timeout"`
+	// Queries 指标名到PromQL模板的映射，模板参数见QueryData
+	Queries map[string]string `json:"queries"`
+}
+
+// QueryData PromQL模板中可以使用的参数
+type QueryData struct {
+	Node       string
+	InternalIP string
+	// Range rate等函数使用的时间窗口，如 5m
+	Range string
+}
+
+var defaultQueries = map[string]string{
+	"cpu":       `100 * (1 - avg(rate(node_cpu_seconds_total{mode="idle",instance=~"{{.InternalIP}}:.*"}[{{.Range}}])))`,
+	"memory":    `100 * (1 - node_memory_MemAvailable_bytes{instance=~"{{.InternalIP}}:.*"} / node_memory_MemTotal_bytes{instance=~"{{.InternalIP}}:.*"})`,
+	"gpu":       `DCGM_FI_DEV_GPU_UTIL{Hostname="{{.Node}}"}`,
+	"gpuMemory": `100 * DCGM_FI_DEV_FB_USED{Hostname="{{.Node}}"} / (DCGM_FI_DEV_FB_USED{Hostname="{{.Node}}"} + DCGM_FI_DEV_FB_FREE{Hostname="{{.Node}}"})`,
+}
+
+func DefaultConfig() *Config {
+	conf := &Config{
+		Timeout: metav1.Duration{Duration: 30 * time.Second},
+		Queries: make(map[string]string, len(defaultQueries)),
+	}
+	for name, query := range defaultQueries {
+		conf.Queries[name] = query
+	}
+	return conf
+}
+
+// LoadConfig 从yaml文件加载配置，未配置的指标使用默认的PromQL
+func LoadConfig(path string) (*Config, error) {
+	conf := DefaultConfig()
+	if len(path) == 0 {
+		return conf, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	if conf.Timeout.Duration <= 0 {
+		conf.Timeout.Duration = 30 * time.Second
+	}
+	return conf, nil
+}
+
+// Render 使用data渲染指标对应的PromQL模板
+func (c *Config) Render(metric string, data QueryData) (string, error) {
+	text, ok := c.Queries[metric]
+	if !ok {
+		return "", fmt.Errorf("unknown metric %q", metric)
+	}
+	tmpl, err := template.New(metric).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
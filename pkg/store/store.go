@@ -0,0 +1,183 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Store 基于bbolt的本地嵌入式存储，value统一以json保存
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put 写入一条记录，bucket不存在时自动创建
+func (s *Store) Put(bucket string, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+}
+
+// Entry 批量写入的一条记录
+type Entry struct {
+	Key   []byte
+	Value any
+}
+
+// Write 在同一个事务中先删除deletes再写入puts，批量写入时只需要一次fsync
+func (s *Store) Write(bucket string, puts []Entry, deletes [][]byte) error {
+	values := make([][]byte, 0, len(puts))
+	for _, entry := range puts {
+		data, err := json.Marshal(entry.Value)
+		if err != nil {
+			return err
+		}
+		values = append(values, data)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		for _, key := range deletes {
+			if err = b.Delete(key); err != nil {
+				return err
+			}
+		}
+		for i, entry := range puts {
+			if err = b.Put(entry.Key, values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan 按key的字典序遍历[min, max)区间内的记录，max为空时遍历到末尾
+func (s *Store) Scan(bucket string, min, max []byte, fn func(key, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(min); k != nil; k, v = c.Next() {
+			if len(max) != 0 && bytes.Compare(k, max) >= 0 {
+				break
+			}
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// TimeKey 生成 prefix + 大端序纳秒时间戳 + suffix 的key，保证同一prefix下按时间有序
+func TimeKey(prefix string, t time.Time, suffix string) []byte {
+	key := make([]byte, 0, len(prefix)+8+len(suffix))
+	key = append(key, prefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(t.UnixNano()))
+	key = append(key, suffix...)
+	return key
+}
+
+// DeleteBefore 删除key小于max的记录，返回删除的条数
+func (s *Store) DeleteBefore(bucket string, max []byte) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, max) < 0; k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		return deleteKeys(b, keys, &deleted)
+	})
+	return deleted, err
+}
+
+// TrimOldest 只保留key最大的keep条记录，用于实现容量固定的环形缓冲
+func (s *Store) TrimOldest(bucket string, keep int) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		excess := b.Stats().KeyN - keep
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && len(keys) < excess; k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+		return deleteKeys(b, keys, &deleted)
+	})
+	return deleted, err
+}
+
+// deleteKeys 遍历结束后再删除，避免边遍历边删除时cursor跳过记录
+func deleteKeys(b *bolt.Bucket, keys [][]byte, deleted *int) error {
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+		*deleted++
+	}
+	return nil
+}
+
+// Delete 删除一条记录，记录不存在时不返回错误
+func (s *Store) Delete(bucket string, key []byte) error {
+	return s.Write(bucket, nil, [][]byte{key})
+}
+
+// Get 读取一条记录并解析到value，记录不存在时返回false
+func (s *Store) Get(bucket string, key []byte, value any) (bool, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(key); v != nil {
+			data = bytes.Clone(v)
+		}
+		return nil
+	})
+	if err != nil || data == nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
+}
@@ -0,0 +1,62 @@
+package usage
+
+import (
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Config 资源用量采样与计费配置
+type Config struct {
+	// Interval 采样间隔
+	Interval metav1.Duration `json:"interval"`
+	// TeamLabel namespace上标识所属团队的label
+	TeamLabel string `json:"teamLabel"`
+	Currency  string `json:"currency"`
+	// Prices 每种资源每小时的单价，cpu按核计，memory/ephemeral-storage按GiB计
+	Prices map[string]float64 `json:"prices"`
+	// GpuPrices 每种GPU型号每卡每小时的单价，default为未配置型号的单价
+	GpuPrices map[string]float64 `json:"gpuPrices"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Interval:  metav1.Duration{Duration: 5 * time.Minute},
+		TeamLabel: "osgalaxy.io/team",
+		Currency:  "CNY",
+		Prices:    map[string]float64{},
+		GpuPrices: map[string]float64{},
+	}
+}
+
+// LoadConfig 从yaml文件加载配置，path为空时使用默认配置
+func LoadConfig(path string) (*Config, error) {
+	conf := DefaultConfig()
+	if len(path) == 0 {
+		return conf, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.Unmarshal(data, conf); err != nil {
+		return nil, err
+	}
+	if conf.Interval.Duration <= 0 {
+		conf.Interval.Duration = 5 * time.Minute
+	}
+	return conf, nil
+}
+
+// UnitPrice 获取资源每小时的单价，gpu资源按型号取价
+func (c *Config) UnitPrice(resource string) float64 {
+	if product, ok := gpuProductOf(resource); ok {
+		if price, ok := c.GpuPrices[product]; ok {
+			return price
+		}
+		return c.GpuPrices["default"]
+	}
+	return c.Prices[resource]
+}
@@ -0,0 +1,142 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"easy-k8s/pkg/store"
+)
+
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+
+	GroupByNamespace = "namespace"
+	GroupByTeam      = "team"
+)
+
+type Report struct {
+	Period   string       `json:"period"`
+	GroupBy  string       `json:"groupBy"`
+	Currency string       `json:"currency"`
+	Rows     []*ReportRow `json:"rows"`
+}
+
+type ReportRow struct {
+	Start         string             `json:"start"`
+	Group         string             `json:"group"`
+	ResourceHours map[string]float64 `json:"resourceHours"`
+	Cost          map[string]float64 `json:"cost"`
+	TotalCost     float64            `json:"totalCost"`
+}
+
+// Reporter 按天/周聚合采样数据，计算资源小时数和费用
+type Reporter struct {
+	conf  *Config
+	store *store.Store
+}
+
+func NewReporter(conf *Config, store *store.Store) *Reporter {
+	return &Reporter{conf: conf, store: store}
+}
+
+func (r *Reporter) Usage(from, to time.Time, period, groupBy string) (*Report, error) {
+	if period != PeriodDaily && period != PeriodWeekly {
+		return nil, fmt.Errorf("unsupported period %q", period)
+	}
+	if groupBy != GroupByNamespace && groupBy != GroupByTeam {
+		return nil, fmt.Errorf("unsupported groupBy %q", groupBy)
+	}
+
+	rows := make(map[string]*ReportRow)
+	err := r.store.Scan(bucketSamples, store.TimeKey("", from, ""), store.TimeKey("", to, ""), func(_, value []byte) error {
+		var sample Sample
+		if err := json.Unmarshal(value, &sample); err != nil {
+			return err
+		}
+		group := sample.Namespace
+		if groupBy == GroupByTeam {
+			group = sample.Team
+			if len(group) == 0 {
+				group = "-"
+			}
+		}
+		start := periodStart(sample.Time, period)
+		key := start + "/" + group
+		row, ok := rows[key]
+		if !ok {
+			row = &ReportRow{Start: start, Group: group, ResourceHours: map[string]float64{}, Cost: map[string]float64{}}
+			rows[key] = row
+		}
+		for name, value := range sample.Resources {
+			hours := value * sample.Interval / 3600
+			row.ResourceHours[name] += hours
+			cost := hours * r.conf.UnitPrice(name)
+			row.Cost[name] += cost
+			row.TotalCost += cost
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Period: period, GroupBy: groupBy, Currency: r.conf.Currency, Rows: make([]*ReportRow, 0, len(rows))}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		if report.Rows[i].Start != report.Rows[j].Start {
+			return report.Rows[i].Start < report.Rows[j].Start
+		}
+		return report.Rows[i].Group < report.Rows[j].Group
+	})
+	return report, nil
+}
+
+// WriteCSV 以 start,group,resource,resourceHours,unitPrice,cost 的格式输出报表
+func (r *Reporter) WriteCSV(w io.Writer, report *Report) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"start", report.GroupBy, "resource", "resourceHours", "unitPrice", "cost", "currency"}); err != nil {
+		return err
+	}
+	for _, row := range report.Rows {
+		resources := make([]string, 0, len(row.ResourceHours))
+		for name := range row.ResourceHours {
+			resources = append(resources, name)
+		}
+		sort.Strings(resources)
+		for _, name := range resources {
+			record := []string{
+				row.Start,
+				row.Group,
+				name,
+				strconv.FormatFloat(row.ResourceHours[name], 'f', 4, 64),
+				strconv.FormatFloat(r.conf.UnitPrice(name), 'f', 4, 64),
+				strconv.FormatFloat(row.Cost[name], 'f', 4, 64),
+				report.Currency,
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func periodStart(t time.Time, period string) string {
+	t = t.Local()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == PeriodWeekly {
+		// 以周一作为一周的开始
+		offset := (int(day.Weekday()) + 6) % 7
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day.Format(time.DateOnly)
+}
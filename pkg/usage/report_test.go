@@ -0,0 +1,175 @@
+package usage
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"easy-k8s/pkg/store"
+)
+
+func newTestStore(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func testConfig() *Config {
+	conf := DefaultConfig()
+	conf.Prices = map[string]float64{"cpu": 0.5, "memory": 0.1}
+	conf.GpuPrices = map[string]float64{"A100": 10, "default": 5}
+	return conf
+}
+
+func putSamples(t *testing.T, db *store.Store, samples ...*Sample) {
+	t.Helper()
+	for _, sample := range samples {
+		if err := db.Put(bucketSamples, store.TimeKey("", sample.Time, sample.Namespace), sample); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 2024-01-01 为周一
+func at(day, hour int) time.Time {
+	return time.Date(2024, 1, day, hour, 0, 0, 0, time.Local)
+}
+
+func hourSample(tm time.Time, namespace, team string, resources map[string]float64) *Sample {
+	return &Sample{Time: tm, Interval: 3600, Namespace: namespace, Team: team, Resources: resources}
+}
+
+func testSamples() []*Sample {
+	return []*Sample{
+		hourSample(at(1, 10), "a", "t1", map[string]float64{"cpu": 2, "memory": 4}),
+		hourSample(at(1, 11), "a", "t1", map[string]float64{"cpu": 2}),
+		hourSample(at(1, 10), "b", "t1", map[string]float64{"cpu": 1, "gpu/A100": 1}),
+		hourSample(at(2, 10), "c", "", map[string]float64{"cpu": 4, "gpu/T4": 1}),
+		hourSample(at(8, 10), "a", "t1", map[string]float64{"cpu": 1}),
+		// 查询范围之外
+		hourSample(at(20, 10), "a", "t1", map[string]float64{"cpu": 100}),
+	}
+}
+
+type wantRow struct {
+	start, group string
+	hours        map[string]float64
+	total        float64
+}
+
+func assertRows(t *testing.T, report *Report, want []wantRow) {
+	t.Helper()
+	if len(report.Rows) != len(want) {
+		for _, row := range report.Rows {
+			t.Logf("row %s/%s %v %v", row.Start, row.Group, row.ResourceHours, row.TotalCost)
+		}
+		t.Fatalf("got %d rows, want %d", len(report.Rows), len(want))
+	}
+	for i, w := range want {
+		row := report.Rows[i]
+		if row.Start != w.start || row.Group != w.group {
+			t.Errorf("row %d = %s/%s, want %s/%s", i, row.Start, row.Group, w.start, w.group)
+			continue
+		}
+		if len(row.ResourceHours) != len(w.hours) {
+			t.Errorf("row %s/%s hours = %v, want %v", w.start, w.group, row.ResourceHours, w.hours)
+		}
+		for name, hours := range w.hours {
+			if math.Abs(row.ResourceHours[name]-hours) > 1e-9 {
+				t.Errorf("row %s/%s %s hours = %v, want %v", w.start, w.group, name, row.ResourceHours[name], hours)
+			}
+		}
+		if math.Abs(row.TotalCost-w.total) > 1e-9 {
+			t.Errorf("row %s/%s total = %v, want %v", w.start, w.group, row.TotalCost, w.total)
+		}
+	}
+}
+
+func TestUsageDailyByNamespace(t *testing.T) {
+	db := newTestStore(t)
+	putSamples(t, db, testSamples()...)
+
+	report, err := NewReporter(testConfig(), db).Usage(at(1, 0), at(15, 0), PeriodDaily, GroupByNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, report, []wantRow{
+		{"2024-01-01", "a", map[string]float64{"cpu": 4, "memory": 4}, 4*0.5 + 4*0.1},
+		{"2024-01-01", "b", map[string]float64{"cpu": 1, "gpu/A100": 1}, 0.5 + 10},
+		// 未配置单价的GPU型号使用default
+		{"2024-01-02", "c", map[string]float64{"cpu": 4, "gpu/T4": 1}, 4*0.5 + 5},
+		{"2024-01-08", "a", map[string]float64{"cpu": 1}, 0.5},
+	})
+}
+
+func TestUsageWeeklyByTeam(t *testing.T) {
+	db := newTestStore(t)
+	putSamples(t, db, testSamples()...)
+
+	report, err := NewReporter(testConfig(), db).Usage(at(1, 0), at(15, 0), PeriodWeekly, GroupByTeam)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, report, []wantRow{
+		// 没有团队label的namespace归到 -
+		{"2024-01-01", "-", map[string]float64{"cpu": 4, "gpu/T4": 1}, 4*0.5 + 5},
+		{"2024-01-01", "t1", map[string]float64{"cpu": 5, "memory": 4, "gpu/A100": 1}, 5*0.5 + 4*0.1 + 10},
+		{"2024-01-08", "t1", map[string]float64{"cpu": 1}, 0.5},
+	})
+}
+
+func TestUsageSubHourInterval(t *testing.T) {
+	db := newTestStore(t)
+	// 5分钟间隔采样12次即为1小时
+	for i := 0; i < 12; i++ {
+		putSamples(t, db, &Sample{Time: at(3, 0).Add(time.Duration(i) * 5 * time.Minute), Interval: 300, Namespace: "a", Resources: map[string]float64{"cpu": 2}})
+	}
+
+	report, err := NewReporter(testConfig(), db).Usage(at(3, 0), at(4, 0), PeriodDaily, GroupByNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRows(t, report, []wantRow{{"2024-01-03", "a", map[string]float64{"cpu": 2}, 1}})
+}
+
+func TestUsageInvalidParams(t *testing.T) {
+	reporter := NewReporter(testConfig(), newTestStore(t))
+	if _, err := reporter.Usage(at(1, 0), at(2, 0), "monthly", GroupByNamespace); err == nil {
+		t.Error("want error for unsupported period")
+	}
+	if _, err := reporter.Usage(at(1, 0), at(2, 0), PeriodDaily, "pod"); err == nil {
+		t.Error("want error for unsupported groupBy")
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	db := newTestStore(t)
+	putSamples(t, db, testSamples()[:3]...)
+	reporter := NewReporter(testConfig(), db)
+	report, err := reporter.Usage(at(1, 0), at(2, 0), PeriodDaily, GroupByNamespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = reporter.WriteCSV(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"start,namespace,resource,resourceHours,unitPrice,cost,currency",
+		"2024-01-01,a,cpu,4.0000,0.5000,2.0000,CNY",
+		"2024-01-01,a,memory,4.0000,0.1000,0.4000,CNY",
+		"2024-01-01,b,cpu,1.0000,0.5000,0.5000,CNY",
+		"2024-01-01,b,gpu/A100,1.0000,10.0000,10.0000,CNY",
+	}, "\n") + "\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
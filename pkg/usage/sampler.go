@@ -0,0 +1,129 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+	eresource "easy-k8s/pkg/k8s/resource"
+	"easy-k8s/pkg/store"
+)
+
+const (
+	bucketSamples = "usageSamples"
+	gpuPrefix     = "gpu/"
+	gib           = 1 << 30
+)
+
+// Sample 某一时刻一个namespace的资源requests快照
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Interval  float64   `json:"interval"`
+	Namespace string    `json:"namespace"`
+	Team      string    `json:"team"`
+	// Resources cpu单位为核，memory/ephemeral-storage单位为GiB，gpu/<product>单位为卡
+	Resources map[string]float64 `json:"resources"`
+}
+
+// Sampler 周期性采集各namespace的资源requests并写入本地存储
+type Sampler struct {
+	log               logr.Logger
+	conf              *Config
+	store             *store.Store
+	nodeInformer      cache.SharedIndexInformer
+	podInformer       cache.SharedIndexInformer
+	namespaceInformer cache.SharedIndexInformer
+}
+
+func NewSampler(log logr.Logger, conf *Config, store *store.Store, nodeInformer, podInformer, namespaceInformer cache.SharedIndexInformer) *Sampler {
+	return &Sampler{
+		log:               log.WithName("UsageSampler"),
+		conf:              conf,
+		store:             store,
+		nodeInformer:      nodeInformer,
+		podInformer:       podInformer,
+		namespaceInformer: namespaceInformer,
+	}
+}
+
+func (s *Sampler) Run(ctx context.Context) {
+	s.log.Info("STARTING usage sampler", "interval", s.conf.Interval.Duration)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := s.sample(time.Now()); err != nil {
+			s.log.Error(err, "sample usage err")
+		}
+	}, s.conf.Interval.Duration)
+}
+
+func (s *Sampler) sample(now time.Time) error {
+	samples := make(map[string]*Sample)
+	for _, obj := range s.podInformer.GetStore().List() {
+		pod := obj.(*v1.Pod)
+		if len(pod.Spec.NodeName) == 0 || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+
+		sample, ok := samples[pod.Namespace]
+		if !ok {
+			sample = &Sample{
+				Time:      now,
+				Interval:  s.conf.Interval.Seconds(),
+				Namespace: pod.Namespace,
+				Team:      s.namespaceTeam(pod.Namespace),
+				Resources: make(map[string]float64),
+			}
+			samples[pod.Namespace] = sample
+		}
+
+		reqs, _ := eresource.PodRequestsAndLimits(pod)
+		for name, quantity := range reqs {
+			switch name {
+			case v1.ResourceCPU:
+				sample.Resources[string(name)] += quantity.AsApproximateFloat64()
+			case v1.ResourceMemory, v1.ResourceEphemeralStorage:
+				sample.Resources[string(name)] += quantity.AsApproximateFloat64() / gib
+			case comm.LabelNVIDIA:
+				sample.Resources[gpuPrefix+s.nodeGpuProduct(pod.Spec.NodeName)] += quantity.AsApproximateFloat64()
+			}
+		}
+	}
+
+	for ns, sample := range samples {
+		if err := s.store.Put(bucketSamples, store.TimeKey("", now, ns), sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sampler) namespaceTeam(name string) string {
+	obj, exists, err := s.namespaceInformer.GetStore().GetByKey(name)
+	if err != nil || !exists {
+		return ""
+	}
+	return obj.(*v1.Namespace).Labels[s.conf.TeamLabel]
+}
+
+func (s *Sampler) nodeGpuProduct(name string) string {
+	obj, exists, err := s.nodeInformer.GetStore().GetByKey(name)
+	if err != nil || !exists {
+		return "unknown"
+	}
+	if product := comm.NodeGpuProduct(obj.(*v1.Node).Labels); len(product) != 0 {
+		return product
+	}
+	return "unknown"
+}
+
+func gpuProductOf(resource string) (string, bool) {
+	if !strings.HasPrefix(resource, gpuPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(resource, gpuPrefix), true
+}
@@ -0,0 +1,106 @@
+package usage
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"easy-k8s/pkg/comm"
+)
+
+func newTestInformer(t *testing.T, example runtime.Object, objs ...runtime.Object) cache.SharedIndexInformer {
+	t.Helper()
+	informer := cache.NewSharedIndexInformer(nil, example, 0, cache.Indexers{})
+	for _, obj := range objs {
+		if err := informer.GetStore().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return informer
+}
+
+func samplePod(namespace, name, node string, phase v1.PodPhase, requests v1.ResourceList) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: v1.PodSpec{
+			NodeName:   node,
+			Containers: []v1.Container{{Name: "app", Resources: v1.ResourceRequirements{Requests: requests}}},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+}
+
+func TestSample(t *testing.T) {
+	db := newTestStore(t)
+	conf := testConfig()
+
+	nodes := newTestInformer(t, &v1.Node{},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "gpu-node", Labels: map[string]string{comm.LabelGpuProduct + "/A100": "true"}}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-node"}},
+	)
+	namespaces := newTestInformer(t, &v1.Namespace{},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{conf.TeamLabel: "t1"}}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+	)
+	requests := v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m"), v1.ResourceMemory: resource.MustParse("512Mi")}
+	gpuRequests := v1.ResourceList{v1.ResourceCPU: resource.MustParse("2"), comm.LabelNVIDIA: resource.MustParse("2")}
+	pods := newTestInformer(t, &v1.Pod{},
+		samplePod("a", "web", "cpu-node", v1.PodRunning, requests),
+		samplePod("a", "train", "gpu-node", v1.PodRunning, gpuRequests),
+		// 未调度和已结束的pod不计费
+		samplePod("a", "pending", "", v1.PodPending, requests),
+		samplePod("a", "done", "cpu-node", v1.PodSucceeded, requests),
+		samplePod("b", "web", "cpu-node", v1.PodRunning, requests),
+	)
+
+	sampler := NewSampler(logr.Discard(), conf, db, nodes, pods, namespaces)
+	now := at(1, 10)
+	if err := sampler.sample(now); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := map[string]*Sample{}
+	err := db.Scan(bucketSamples, nil, nil, func(_, value []byte) error {
+		sample := &Sample{}
+		if err := json.Unmarshal(value, sample); err != nil {
+			return err
+		}
+		samples[sample.Namespace] = sample
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		team      string
+		resources map[string]float64
+	}{
+		"a": {"t1", map[string]float64{"cpu": 2.5, "memory": 0.5, "gpu/A100": 2}},
+		"b": {"", map[string]float64{"cpu": 0.5, "memory": 0.5}},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(samples), len(want))
+	}
+	for ns, w := range want {
+		sample := samples[ns]
+		if sample.Team != w.team || !sample.Time.Equal(now) || sample.Interval != conf.Interval.Seconds() {
+			t.Errorf("sample %s = %+v", ns, sample)
+		}
+		if len(sample.Resources) != len(w.resources) {
+			t.Errorf("sample %s resources = %v, want %v", ns, sample.Resources, w.resources)
+		}
+		for name, value := range w.resources {
+			if math.Abs(sample.Resources[name]-value) > 1e-9 {
+				t.Errorf("sample %s %s = %v, want %v", ns, name, sample.Resources[name], value)
+			}
+		}
+	}
+}